@@ -5,6 +5,7 @@ import (
 	"gateway/internal/handler"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -24,6 +25,15 @@ func main() {
 		os.Getenv("CENSORSHIP_SERVICE_URL"),
 	)
 
+	if raw := os.Getenv("PUBLIC_URL"); raw != "" {
+		publicURL, err := url.Parse(raw)
+		if err != nil || (publicURL.Scheme != "http" && publicURL.Scheme != "https") || publicURL.Host == "" {
+			slog.Error("invalid PUBLIC_URL", "url", raw)
+			os.Exit(1)
+		}
+		h.SetPublicURL(publicURL)
+	}
+
 	srv := &http.Server{
 		Addr:    ":8080",
 		Handler: h.Router(),
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +21,7 @@ type Handler struct {
 	newsServiceURL       string
 	commentsServiceURL   string
 	censorshipServiceURL string
+	publicURL            *url.URL
 }
 
 // NewHandler creates and initializes a new Handler instance.
@@ -33,37 +35,64 @@ func New(newsURL, commentsURL, censorshipURL string) *Handler {
 	return &h
 }
 
+// SetPublicURL sets the address readers reach the gateway at. The news service builds the links
+// of the published feeds from it, as the addresses of the requests come from the clients.
+func (h *Handler) SetPublicURL(u *url.URL) {
+	h.publicURL = u
+}
+
 // Router returns the request router.
 func (h *Handler) Router() *mux.Router {
 	return h.router
 }
 
 // RegisterRoutes registers all API Gateway routes.
+// Feeds can be listed, exported and discovered through the gateway, but not added, changed
+// or removed: the gateway has no authentication, and the news service fetches whatever feed
+// it is given. Feeds are managed on the news service itself.
 func (h *Handler) registerRoutes() {
 	h.router.Use(h.jsonMiddleware)
 	h.router.Use(h.requestIDMiddleware)
 	h.router.Use(h.loggingMiddleware)
 	h.router.HandleFunc("/news", h.newsListHandler).Methods(http.MethodGet)
 	h.router.HandleFunc("/news/filter", h.newsFilterHandler).Methods(http.MethodGet)
+	h.router.HandleFunc("/news.rss", h.newsFeedHandler).Methods(http.MethodGet)
+	h.router.HandleFunc("/news.atom", h.newsFeedHandler).Methods(http.MethodGet)
+	h.router.HandleFunc("/news/cluster/{id}", h.newsClusterHandler).Methods(http.MethodGet)
 	h.router.HandleFunc("/news/{id}", h.newsDetailedHandler).Methods(http.MethodGet)
 	h.router.HandleFunc("/news/{id}/comment", h.addCommentHandler).Methods(http.MethodPost)
+	h.router.HandleFunc("/tags", h.tagsHandler).Methods(http.MethodGet)
+	h.router.HandleFunc("/feeds", h.feedsHandler).Methods(http.MethodGet)
+	h.router.HandleFunc("/feeds/discover", h.discoverFeedsHandler).Methods(http.MethodPost)
+	h.router.HandleFunc("/feeds.opml", h.exportFeedsHandler).Methods(http.MethodGet)
+	h.router.HandleFunc("/feeds/{id}", h.feedHandler).Methods(http.MethodGet)
 }
 
-// newsListHandler proxies the request for the list of news.
+// newsListParams are the news list query parameters forwarded to the news service.
+var newsListParams = []string{"source", "tag", "from", "to", "q", "sort", "duplicates", "page", "per_page", "cursor", "limit", "format"}
+
+// newsListHandler proxies the request for the list of news, passing filter, page and cursor parameters through.
 func (h *Handler) newsListHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := getRequestID(r.Context())
 
-	page := r.URL.Query().Get("page")
-	var url string
+	q := url.Values{}
+	q.Set("request_id", requestID)
+	for _, name := range newsListParams {
+		if r.URL.Query().Has(name) {
+			q.Set(name, r.URL.Query().Get(name))
+		}
+	}
 
-	if page != "" {
-		url = fmt.Sprintf("%s/news?page=%s&request_id=%s", h.newsServiceURL, page, requestID)
+	// Without page or cursor parameters the gateway asks for the latest 40 news.
+	var target string
+	if q.Has("page") || q.Has("per_page") || q.Has("cursor") || q.Has("limit") {
+		target = fmt.Sprintf("%s/news?%s", h.newsServiceURL, q.Encode())
 	} else {
 		limit := "40"
-		url = fmt.Sprintf("%s/news/%s?request_id=%s", h.newsServiceURL, limit, requestID)
+		target = fmt.Sprintf("%s/news/%s?%s", h.newsServiceURL, limit, q.Encode())
 	}
 
-	resp, err := http.Get(url)
+	resp, err := http.Get(target)
 	if err != nil {
 		slog.Error("newsHandler: failed to get news list", "err", err, "request_id", requestID)
 		http.Error(w, "failed to fetch news", http.StatusBadGateway)
@@ -71,6 +100,64 @@ func (h *Handler) newsListHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
+	writePaginated(w, r, resp)
+}
+
+// newsFeedParams are the filters of the published news feeds forwarded to the news service.
+var newsFeedParams = []string{"source", "tag", "from", "to", "q", "duplicates", "per_page"}
+
+// newsFeedHeaders are the response headers of the published news feeds kept by the gateway,
+// so that readers get the feed type and can poll with conditional requests.
+var newsFeedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Cache-Control"}
+
+// newsFeedHandler proxies the RSS and Atom feeds of the news list with their filters. The
+// conditional request headers of the reader are passed on. The feeds are cached publicly, so
+// the Host and X-Forwarded-* headers of the reader are not: the links of the feed are given
+// the public URL of the gateway in X-Forwarded-Host and X-Forwarded-Proto, if one is set.
+func (h *Handler) newsFeedHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	q := url.Values{}
+	q.Set("request_id", requestID)
+	for _, name := range newsFeedParams {
+		if r.URL.Query().Has(name) {
+			q.Set(name, r.URL.Query().Get(name))
+		}
+	}
+	target := fmt.Sprintf("%s%s?%s", h.newsServiceURL, r.URL.Path, q.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		slog.Error("newsFeedHandler: failed to create request", "err", err, "request_id", requestID)
+		http.Error(w, "failed to create request", http.StatusInternalServerError)
+		return
+	}
+	for _, name := range []string{"If-None-Match", "If-Modified-Since"} {
+		if v := r.Header.Get(name); v != "" {
+			req.Header.Set(name, v)
+		}
+	}
+	if h.publicURL != nil {
+		req.Header.Set("X-Forwarded-Proto", h.publicURL.Scheme)
+		req.Header.Set("X-Forwarded-Host", h.publicURL.Host)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("newsFeedHandler: failed to get news feed", "err", err, "request_id", requestID)
+		http.Error(w, "failed to fetch news feed", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Not JSON: the type is the news service's, if any.
+	w.Header().Del("Content-Type")
+	for _, name := range newsFeedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
 	w.WriteHeader(resp.StatusCode)
 	io.Copy(w, resp.Body)
 }
@@ -85,9 +172,16 @@ func (h *Handler) newsFilterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url := fmt.Sprintf("%s/news/filter?s=%s&request_id=%s", h.newsServiceURL, query, requestID)
+	q := url.Values{}
+	q.Set("s", query)
+	q.Set("request_id", requestID)
+	for _, name := range []string{"source", "tag", "from", "to", "sort", "duplicates", "page", "per_page", "format"} {
+		if r.URL.Query().Has(name) {
+			q.Set(name, r.URL.Query().Get(name))
+		}
+	}
 
-	resp, err := http.Get(url)
+	resp, err := http.Get(fmt.Sprintf("%s/news/filter?%s", h.newsServiceURL, q.Encode()))
 	if err != nil {
 		slog.Error("filterHandler: failed to get filtered news", "err", err, "request_id", requestID)
 		http.Error(w, "failed to fetch filtered news", http.StatusBadGateway)
@@ -95,6 +189,153 @@ func (h *Handler) newsFilterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
+	writePaginated(w, r, resp)
+}
+
+// formatQuery returns the query for single posts and clusters: the request ID and the content format, if any.
+func formatQuery(r *http.Request, requestID string) url.Values {
+	q := url.Values{}
+	q.Set("request_id", requestID)
+	if r.URL.Query().Has("format") {
+		q.Set("format", r.URL.Query().Get("format"))
+	}
+	return q
+}
+
+// newsClusterHandler proxies the request for the posts of a story cluster.
+func (h *Handler) newsClusterHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	id := mux.Vars(r)["id"]
+	url := fmt.Sprintf("%s/news/cluster/%s?%s", h.newsServiceURL, id, formatQuery(r, requestID).Encode())
+
+	resp, err := http.Get(url)
+	if err != nil {
+		slog.Error("newsClusterHandler: failed to get story cluster", "err", err, "request_id", requestID)
+		http.Error(w, "failed to fetch story cluster", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// feedsHandler proxies the request for the health of all feeds.
+func (h *Handler) feedsHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	url := fmt.Sprintf("%s/feeds?request_id=%s", h.newsServiceURL, requestID)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		slog.Error("feedsHandler: failed to get feeds", "err", err, "request_id", requestID)
+		http.Error(w, "failed to fetch feeds", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// tagsHandler proxies the request for the tags with their post counts.
+func (h *Handler) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	url := fmt.Sprintf("%s/tags?request_id=%s", h.newsServiceURL, requestID)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		slog.Error("tagsHandler: failed to get tags", "err", err, "request_id", requestID)
+		http.Error(w, "failed to fetch tags", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// feedHandler proxies the request for the health of a single feed.
+func (h *Handler) feedHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	id := mux.Vars(r)["id"]
+	url := fmt.Sprintf("%s/feeds/%s?request_id=%s", h.newsServiceURL, id, requestID)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		slog.Error("feedHandler: failed to get feed", "err", err, "request_id", requestID)
+		http.Error(w, "failed to fetch feed", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// exportFeedsHandler proxies the OPML export of the feed list, keeping its content type and file name.
+func (h *Handler) exportFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	url := fmt.Sprintf("%s/feeds.opml?request_id=%s", h.newsServiceURL, requestID)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		slog.Error("exportFeedsHandler: failed to get feeds", "err", err, "request_id", requestID)
+		http.Error(w, "failed to fetch feeds", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	if cd := resp.Header.Get("Content-Disposition"); cd != "" {
+		w.Header().Set("Content-Disposition", cd)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// discoverFeedsHandler proxies feed discovery requests to the news service. Discovery fetches
+// the page and every candidate feed, so it is given more time than other feed requests.
+func (h *Handler) discoverFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	h.proxyFeedRequest(w, r, 30*time.Second)
+}
+
+// proxyFeedRequest forwards a feed request with its method, path, body and content type (JSON
+// unless given) to the news service and copies the response back, with the Retry-After of
+// requests the news service is too busy for.
+func (h *Handler) proxyFeedRequest(w http.ResponseWriter, r *http.Request, timeout time.Duration) {
+	requestID := getRequestID(r.Context())
+
+	url := fmt.Sprintf("%s%s?request_id=%s", h.newsServiceURL, r.URL.Path, requestID)
+
+	req, err := http.NewRequest(r.Method, url, r.Body)
+	if err != nil {
+		slog.Error("proxyFeedRequest: failed to create request", "err", err, "request_id", requestID)
+		http.Error(w, "failed to create request", http.StatusInternalServerError)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("proxyFeedRequest: failed to send request", "err", err, "request_id", requestID)
+		http.Error(w, "failed to send request to news service", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if retry := resp.Header.Get("Retry-After"); retry != "" {
+		w.Header().Set("Retry-After", retry)
+	}
 	w.WriteHeader(resp.StatusCode)
 	io.Copy(w, resp.Body)
 }
@@ -121,7 +362,7 @@ func (h *Handler) newsDetailedHandler(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		defer wg.Done()
 
-		newsURL := fmt.Sprintf("%s/news/new/%s?request_id=%s", h.newsServiceURL, id, requestID)
+		newsURL := fmt.Sprintf("%s/news/new/%s?%s", h.newsServiceURL, id, formatQuery(r, requestID).Encode())
 		newsResp, err := http.Get(newsURL)
 		if err != nil {
 			slog.Error("newsDetailedHandler: failed to fetch news", "err", err, "request_id", requestID)
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"gateway/internal/models"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -16,6 +18,8 @@ func TestHandler_newsListHandler(t *testing.T) {
 		wantStatus int
 		wantInBody string
 		route      string
+		wantQuery  string
+		wantLink   string
 	}{
 		{
 			name:       "with page",
@@ -33,11 +37,60 @@ func TestHandler_newsListHandler(t *testing.T) {
 			wantInBody: "Test News",
 			route:      "/news?request_id=abc123",
 		},
+		{
+			name:       "with source",
+			newsStatus: http.StatusOK,
+			newsBody:   `{"id":1,"title":"Test News","content":"content","source":{"id":3}}`,
+			wantStatus: http.StatusOK,
+			wantInBody: "Test News",
+			route:      "/news?page=2&source=3",
+			wantQuery:  "source=3",
+		},
+		{
+			name:       "with tag",
+			newsStatus: http.StatusOK,
+			newsBody:   `{"News":[{"ID":1,"Title":"Test News","Tags":["go"]}]}`,
+			wantStatus: http.StatusOK,
+			wantInBody: `"Tags":["go"]`,
+			route:      "/news?tag=go",
+			wantQuery:  "tag=go",
+		},
+		{
+			name:       "with cursor",
+			newsStatus: http.StatusOK,
+			newsBody:   `{"News":[{"ID":1,"Title":"Test News"}],"NextCursor":"bjoxOjI"}`,
+			wantStatus: http.StatusOK,
+			wantInBody: "NextCursor",
+			route:      "/news?cursor=bjoxOjI&limit=10",
+			wantQuery:  "cursor=bjoxOjI&limit=10",
+		},
+		{
+			name:       "with filters",
+			newsStatus: http.StatusOK,
+			newsBody:   `[{"ID":1,"Title":"Test News"}]`,
+			wantStatus: http.StatusOK,
+			wantInBody: "Test News",
+			route:      "/news?q=go&sort=-pub_time&from=2024-01-01T00%3A00%3A00Z&to=2024-02-01T00%3A00%3A00Z",
+			wantQuery:  "from=2024-01-01T00%3A00%3A00Z&q=go&request_id=",
+		},
+		{
+			name:       "with per_page",
+			newsStatus: http.StatusOK,
+			newsBody:   `{"News":[],"Pagination":{"CurrentPage":2,"TotalPages":3,"PerPage":5,"TotalItems":12,"HasNext":true,"HasPrev":true}}`,
+			wantStatus: http.StatusOK,
+			route:      "/news?page=2&per_page=5",
+			wantQuery:  "per_page=5",
+			wantLink: `</news?page=1&per_page=5>; rel="first", </news?page=1&per_page=5>; rel="prev", ` +
+				`</news?page=3&per_page=5>; rel="next", </news?page=3&per_page=5>; rel="last"`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.wantQuery != "" && !strings.Contains(r.URL.RawQuery, tt.wantQuery) {
+					t.Errorf("[%s] query = %s, want substring %q", tt.name, r.URL.RawQuery, tt.wantQuery)
+				}
 				if tt.newsStatus != 0 {
 					w.WriteHeader(tt.newsStatus)
 				} else {
@@ -62,6 +115,45 @@ func TestHandler_newsListHandler(t *testing.T) {
 			if tt.wantInBody != "" && !strings.Contains(rr.Body.String(), tt.wantInBody) {
 				t.Errorf("[%s] body = %s, want substring %q", tt.name, rr.Body.String(), tt.wantInBody)
 			}
+
+			if got := rr.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("[%s] Link = %q, want %q", tt.name, got, tt.wantLink)
+			}
+		})
+	}
+}
+
+func Test_linkHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		route string
+		page  models.Pagination
+		want  string
+	}{
+		{
+			name:  "first page",
+			route: "/news?source=2&request_id=abc",
+			page:  models.Pagination{CurrentPage: 1, TotalPages: 2, HasNext: true},
+			want:  `</news?page=1&source=2>; rel="first", </news?page=2&source=2>; rel="next", </news?page=2&source=2>; rel="last"`,
+		},
+		{
+			name:  "last page",
+			route: "/news/filter?s=go&page=2",
+			page:  models.Pagination{CurrentPage: 2, TotalPages: 2, HasPrev: true},
+			want:  `</news/filter?page=1&s=go>; rel="first", </news/filter?page=1&s=go>; rel="prev", </news/filter?page=2&s=go>; rel="last"`,
+		},
+		{
+			name:  "empty list",
+			route: "/news?page=1",
+			page:  models.Pagination{CurrentPage: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.route)
+			if got := linkHeader(u, tt.page); got != tt.want {
+				t.Errorf("linkHeader() = %q, want %q", got, tt.want)
+			}
 		})
 	}
 }
@@ -74,6 +166,7 @@ func TestHandler_newsFilterHandler(t *testing.T) {
 		wantStatus int
 		wantInBody string
 		route      string
+		wantQuery  string
 	}{
 		{
 			name:       "success",
@@ -90,11 +183,26 @@ func TestHandler_newsFilterHandler(t *testing.T) {
 			wantStatus: http.StatusBadRequest,
 			route:      "/news/filter?test&request_id=abc123",
 		},
+		{
+			name:       "cyrillic query with page",
+			newsStatus: http.StatusOK,
+			newsBody:   `{"News":[{"ID":1,"Title":"Новости"}]}`,
+			wantStatus: http.StatusOK,
+			wantInBody: "Новости",
+			route:      "/news/filter?s=%D0%BD%D0%BE%D0%B2%D0%BE%D1%81%D1%82%D0%B8+%D1%80%D1%8B%D0%BD%D0%BA%D0%B0&page=2",
+			wantQuery:  "page=2&request_id=",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.wantQuery != "" && !strings.Contains(r.URL.RawQuery, tt.wantQuery) {
+					t.Errorf("[%s] query = %s, want substring %q", tt.name, r.URL.RawQuery, tt.wantQuery)
+				}
+				if tt.wantQuery != "" && r.URL.Query().Get("s") != "новости рынка" {
+					t.Errorf("[%s] s = %q, want %q", tt.name, r.URL.Query().Get("s"), "новости рынка")
+				}
 				if tt.newsStatus != 0 {
 					w.WriteHeader(tt.newsStatus)
 				} else {
@@ -123,6 +231,362 @@ func TestHandler_newsFilterHandler(t *testing.T) {
 	}
 }
 
+func TestHandler_feedsHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		newsStatus int
+		newsBody   string
+		wantStatus int
+		wantInBody string
+		wantPath   string
+		route      string
+	}{
+		{
+			name:       "list",
+			newsStatus: http.StatusOK,
+			newsBody:   `[{"ID":1,"URL":"https://example.com/feed","Failures":0}]`,
+			wantStatus: http.StatusOK,
+			wantInBody: "https://example.com/feed",
+			wantPath:   "/feeds",
+			route:      "/feeds?request_id=abc123",
+		},
+		{
+			name:       "by id",
+			newsStatus: http.StatusOK,
+			newsBody:   `{"ID":1,"URL":"https://example.com/feed","Failures":3}`,
+			wantStatus: http.StatusOK,
+			wantInBody: `"Failures":3`,
+			wantPath:   "/feeds/1",
+			route:      "/feeds/1?request_id=abc123",
+		},
+		{
+			name:       "tags",
+			newsStatus: http.StatusOK,
+			newsBody:   `[{"Name":"go","Posts":3}]`,
+			wantStatus: http.StatusOK,
+			wantInBody: `"Posts":3`,
+			wantPath:   "/tags",
+			route:      "/tags?request_id=abc123",
+		},
+		{
+			name:       "not found",
+			newsStatus: http.StatusNotFound,
+			newsBody:   "feed not found",
+			wantStatus: http.StatusNotFound,
+			wantPath:   "/feeds/42",
+			route:      "/feeds/42?request_id=abc123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				w.WriteHeader(tt.newsStatus)
+				io.WriteString(w, tt.newsBody)
+			}))
+			defer newsSrv.Close()
+
+			h := New(newsSrv.URL, "", "")
+			req := httptest.NewRequest(http.MethodGet, tt.route, nil)
+			rr := httptest.NewRecorder()
+
+			h.Router().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("[%s] got %d, want %d", tt.name, rr.Code, tt.wantStatus)
+			}
+
+			if gotPath != tt.wantPath {
+				t.Errorf("[%s] news service path = %q, want %q", tt.name, gotPath, tt.wantPath)
+			}
+
+			if tt.wantInBody != "" && !strings.Contains(rr.Body.String(), tt.wantInBody) {
+				t.Errorf("[%s] body = %s, want substring %q", tt.name, rr.Body.String(), tt.wantInBody)
+			}
+		})
+	}
+}
+
+func TestHandler_exportFeedsHandler(t *testing.T) {
+	const list = `<opml version="2.0"><body><outline text="Go" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/></body></opml>`
+	newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feeds.opml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="feeds.opml"`)
+		io.WriteString(w, list)
+	}))
+	defer newsSrv.Close()
+
+	h := New(newsSrv.URL, "", "")
+	req := httptest.NewRequest(http.MethodGet, "/feeds.opml", nil)
+	rr := httptest.NewRecorder()
+
+	h.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d, want %d", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/x-opml; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want the OPML type of the news service", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="feeds.opml"` {
+		t.Errorf("Content-Disposition = %q, want the file name of the news service", cd)
+	}
+	if rr.Body.String() != list {
+		t.Errorf("body = %s, want %s", rr.Body.String(), list)
+	}
+}
+
+func TestHandler_newsFeedHandler(t *testing.T) {
+	const etag = `"abc"`
+	var gotPath, gotQuery, gotHost, gotProto string
+	newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		gotHost, gotProto = r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Forwarded-Proto")
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Header().Set("Last-Modified", "Tue, 06 Feb 2024 10:00:00 GMT")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".atom") {
+			w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		}
+		io.WriteString(w, `<rss version="2.0"><channel><title>News</title></channel></rss>`)
+	}))
+	defer newsSrv.Close()
+
+	h := New(newsSrv.URL, "", "")
+	h.SetPublicURL(&url.URL{Scheme: "https", Host: "news.example.com"})
+
+	tests := []struct {
+		name            string
+		route           string
+		ifNoneMatch     string
+		wantStatus      int
+		wantContentType string
+		wantPath        string
+		wantQuery       []string
+	}{
+		{
+			name:            "rss with filters",
+			route:           "/news.rss?q=go&source=3&tag=go&page=2&request_id=abc123",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/rss+xml; charset=utf-8",
+			wantPath:        "/news.rss",
+			wantQuery:       []string{"q=go", "source=3", "tag=go", "request_id=abc123"},
+		},
+		{
+			name:            "atom",
+			route:           "/news.atom",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/atom+xml; charset=utf-8",
+			wantPath:        "/news.atom",
+		},
+		{
+			name:        "not modified",
+			route:       "/news.rss",
+			ifNoneMatch: etag,
+			wantStatus:  http.StatusNotModified,
+			wantPath:    "/news.rss",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Readers cannot change the links of the cached feed.
+			req := httptest.NewRequest(http.MethodGet, tt.route, nil)
+			req.Host = "evil.example.com"
+			req.Header.Set("X-Forwarded-Host", "evil.example.com")
+			req.Header.Set("X-Forwarded-Proto", "http")
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+
+			h.Router().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("[%s] got %d, want %d", tt.name, rr.Code, tt.wantStatus)
+			}
+			if gotPath != tt.wantPath || gotHost != "news.example.com" || gotProto != "https" {
+				t.Errorf("[%s] news service got %s from %s://%s, want %s from https://news.example.com", tt.name, gotPath, gotProto, gotHost, tt.wantPath)
+			}
+			for _, s := range tt.wantQuery {
+				if !strings.Contains(gotQuery, s) {
+					t.Errorf("[%s] news service query = %q, want %q", tt.name, gotQuery, s)
+				}
+			}
+			if strings.Contains(gotQuery, "page=") {
+				t.Errorf("[%s] news service query = %q, want no page", tt.name, gotQuery)
+			}
+			if rr.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("[%s] Content-Type = %q, want %q", tt.name, rr.Header().Get("Content-Type"), tt.wantContentType)
+			}
+			if rr.Header().Get("ETag") != etag || rr.Header().Get("Cache-Control") != "public, max-age=300" || rr.Header().Get("Last-Modified") == "" {
+				t.Errorf("[%s] caching headers = %v, want those of the news service", tt.name, rr.Header())
+			}
+		})
+	}
+
+	t.Run("no public url", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/news.rss", nil)
+		req.Header.Set("X-Forwarded-Host", "evil.example.com")
+		rr := httptest.NewRecorder()
+
+		New(newsSrv.URL, "", "").Router().ServeHTTP(rr, req)
+
+		if gotHost != "" || gotProto != "" {
+			t.Errorf("news service got %s://%s, want no forwarded address", gotProto, gotHost)
+		}
+	})
+}
+
+func TestHandler_newsClusterHandler(t *testing.T) {
+	var gotPath, gotFormat string
+	newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotFormat = r.URL.Query().Get("format")
+		io.WriteString(w, `[{"ID":1,"ClusterID":1},{"ID":5,"ClusterID":1}]`)
+	}))
+	defer newsSrv.Close()
+
+	h := New(newsSrv.URL, "", "")
+	req := httptest.NewRequest(http.MethodGet, "/news/cluster/1?format=html", nil)
+	rr := httptest.NewRecorder()
+
+	h.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got %d, want %d", rr.Code, http.StatusOK)
+	}
+	if gotPath != "/news/cluster/1" {
+		t.Errorf("news service path = %q, want %q", gotPath, "/news/cluster/1")
+	}
+	if gotFormat != "html" {
+		t.Errorf("news service format = %q, want %q", gotFormat, "html")
+	}
+	if !strings.Contains(rr.Body.String(), `"ID":5`) {
+		t.Errorf("body = %s, want the cluster posts", rr.Body.String())
+	}
+}
+
+func TestHandler_discoverFeedsHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		route      string
+		inputBody  string
+		newsStatus int
+		newsBody   string
+		wantStatus int
+		wantPath   string
+	}{
+		{
+			name:       "discover",
+			method:     http.MethodPost,
+			route:      "/feeds/discover?request_id=abc123",
+			inputBody:  `{"URL":"https://example.com"}`,
+			newsStatus: http.StatusOK,
+			newsBody:   `[{"URL":"https://example.com/feed","Title":"Example","Items":10}]`,
+			wantStatus: http.StatusOK,
+			wantPath:   "/feeds/discover",
+		},
+		{
+			name:       "discover busy",
+			method:     http.MethodPost,
+			route:      "/feeds/discover?request_id=abc123",
+			inputBody:  `{"URL":"https://example.com"}`,
+			newsStatus: http.StatusTooManyRequests,
+			newsBody:   "too many feed discoveries, try again later\n",
+			wantStatus: http.StatusTooManyRequests,
+			wantPath:   "/feeds/discover",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMethod, gotPath, gotBody string
+			newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotMethod = r.Method
+				gotPath = r.URL.Path
+				b, _ := io.ReadAll(r.Body)
+				gotBody = string(b)
+				if tt.newsStatus == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "10")
+				}
+				w.WriteHeader(tt.newsStatus)
+				io.WriteString(w, tt.newsBody)
+			}))
+			defer newsSrv.Close()
+
+			h := New(newsSrv.URL, "", "")
+			req := httptest.NewRequest(tt.method, tt.route, strings.NewReader(tt.inputBody))
+			rr := httptest.NewRecorder()
+
+			h.Router().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("[%s] got %d, want %d", tt.name, rr.Code, tt.wantStatus)
+			}
+			if gotMethod != tt.method || gotPath != tt.wantPath {
+				t.Errorf("[%s] news service got %s %s, want %s %s", tt.name, gotMethod, gotPath, tt.method, tt.wantPath)
+			}
+			if gotBody != tt.inputBody {
+				t.Errorf("[%s] news service body = %q, want %q", tt.name, gotBody, tt.inputBody)
+			}
+			if tt.newsStatus == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "10" {
+				t.Errorf("[%s] Retry-After = %q, want %q", tt.name, rr.Header().Get("Retry-After"), "10")
+			}
+		})
+	}
+}
+
+func TestHandler_manageFeedsNotProxied(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		route  string
+	}{
+		{name: "create", method: http.MethodPost, route: "/feeds"},
+		{name: "import", method: http.MethodPost, route: "/feeds.opml"},
+		{name: "update", method: http.MethodPatch, route: "/feeds/1"},
+		{name: "delete", method: http.MethodDelete, route: "/feeds/1"},
+	}
+
+	called := false
+	newsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer newsSrv.Close()
+
+	h := New(newsSrv.URL, "", "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.route, strings.NewReader(`{"URL":"http://169.254.169.254/"}`))
+			rr := httptest.NewRecorder()
+
+			h.Router().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusMethodNotAllowed {
+				t.Errorf("[%s] got %d, want %d", tt.name, rr.Code, http.StatusMethodNotAllowed)
+			}
+		})
+	}
+	if called {
+		t.Errorf("news service was called for a feed change")
+	}
+}
+
 func TestHandler_newsDetailedHandler(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -144,6 +608,26 @@ func TestHandler_newsDetailedHandler(t *testing.T) {
 			wantInBody:     "Test News",
 			route:          "/news/1?request_id=abc123",
 		},
+		{
+			name:           "media",
+			newsStatus:     http.StatusOK,
+			newsBody:       `{"ID":1,"Title":"Episode","Media":[{"URL":"https://cdn.example.com/1.mp3","Type":"audio/mpeg","Length":42}],"Tags":["podcasts"]}`,
+			commentsStatus: http.StatusOK,
+			commentsBody:   `[]`,
+			wantStatus:     http.StatusOK,
+			wantInBody:     `"media":[{"url":"https://cdn.example.com/1.mp3","type":"audio/mpeg","length":42,"width":0,"height":0}],"tags":["podcasts"]`,
+			route:          "/news/1?request_id=abc123",
+		},
+		{
+			name:           "author",
+			newsStatus:     http.StatusOK,
+			newsBody:       `{"ID":1,"Title":"Episode","Author":"Jane Doe"}`,
+			commentsStatus: http.StatusOK,
+			commentsBody:   `[]`,
+			wantStatus:     http.StatusOK,
+			wantInBody:     `"title":"Episode","author":"Jane Doe"`,
+			route:          "/news/1?request_id=abc123",
+		},
 		{
 			name:       "news service down",
 			newsStatus: http.StatusInternalServerError,
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"gateway/internal/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// writePaginated copies a page received from the news service to the client and,
+// when the page carries pagination details, adds an RFC 8288 Link header with
+// first/prev/next/last links so that clients can page without reading the body.
+func writePaginated(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error("writePaginated: failed to read news response", "err", err, "request_id", getRequestID(r.Context()))
+		http.Error(w, "failed to fetch news", http.StatusBadGateway)
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		var page struct {
+			Pagination *models.Pagination
+		}
+		if json.Unmarshal(body, &page) == nil && page.Pagination != nil {
+			if link := linkHeader(r.URL, *page.Pagination); link != "" {
+				w.Header().Set("Link", link)
+			}
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+}
+
+// linkHeader builds the Link header value for the page, reusing the client's path and query
+// with only the page number changed. An empty list yields no links.
+func linkHeader(u *url.URL, p models.Pagination) string {
+	if p.TotalPages == 0 {
+		return ""
+	}
+
+	link := func(page int, rel string) string {
+		q := u.Query()
+		q.Del("request_id")
+		q.Set("page", strconv.Itoa(page))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, q.Encode(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if p.HasPrev {
+		links = append(links, link(p.CurrentPage-1, "prev"))
+	}
+	if p.HasNext {
+		links = append(links, link(p.CurrentPage+1, "next"))
+	}
+	links = append(links, link(p.TotalPages, "last"))
+
+	return strings.Join(links, ", ")
+}
@@ -12,9 +12,39 @@ type NewsFullDetailed struct {
 type NewsShortDetailed struct {
 	ID      int       `json:"id"`
 	Title   string    `json:"title"`
+	Author  string    `json:"author"`
 	Content string    `json:"content"`
 	PubTime time.Time `json:"pub_time"`
 	Link    string    `json:"link"`
+	Source  Source    `json:"source"`
+	Media   []Media   `json:"media"`
+	Tags    []string  `json:"tags"`
+}
+
+// Media is an image, audio or video attached to a news item; zero sizes are unknown.
+type Media struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"`
+	Length int64  `json:"length"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Source identifies the feed a news item was collected from.
+type Source struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
+
+// Pagination describes a page of news returned by the news service.
+type Pagination struct {
+	CurrentPage int
+	TotalPages  int
+	PerPage     int
+	TotalItems  int
+	HasNext     bool
+	HasPrev     bool
 }
 
 // Comment represents the structure of a user's comment on a news item.
@@ -4,9 +4,13 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"news/internal/config"
 	"news/pkg/aggregator"
 	"news/pkg/api"
+	"news/pkg/discover"
+	"news/pkg/extract"
+	"news/pkg/opml"
 	"news/pkg/storage"
 	"news/pkg/storage/postgres"
 	"os"
@@ -37,6 +41,13 @@ func main() {
 	}
 	defer db.Close()
 	apiSrv := api.New(db)
+	finder := discover.New(10*time.Second, cnf.MaxBodySize)
+	apiSrv.SetDiscoverer(finder)
+	if cnf.PublicURL != "" {
+		publicURL, _ := url.Parse(cnf.PublicURL) // checked by cnf.Load
+		apiSrv.SetPublicURL(publicURL)
+	}
+	apiSrv.SetTrustedProxies(cnf.Proxies)
 
 	postsCh := make(chan []storage.Post)
 	errsCh := make(chan error)
@@ -44,7 +55,39 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	ag := aggregator.New(cnf.RSS, time.Duration(cnf.RequestPeriod)*time.Minute)
+	existing, err := db.Feeds()
+	if err != nil {
+		slog.Error("could not load feeds", "err", err)
+		os.Exit(1)
+	}
+	firstStart := len(existing) == 0
+
+	err = seedFeeds(db, cnf.RSS)
+	if err != nil {
+		slog.Error("could not seed feeds", "err", err)
+		os.Exit(1)
+	}
+
+	err = importOPML(db, cnf.OPML)
+	if err != nil {
+		slog.Error("could not import OPML feed list", "path", cnf.OPML, "err", err)
+		os.Exit(1)
+	}
+
+	if firstStart {
+		// Discovery fetches every site and its candidate feeds; the aggregator picks up
+		// the feeds it adds on its next tick.
+		go func() {
+			err := discoverFeeds(ctx, db, finder, cnf.Discover)
+			if err != nil {
+				slog.Error("could not add discovered feeds", "err", err)
+			}
+		}()
+	}
+
+	limits := aggregator.Limits{Workers: cnf.Concurrency, PerHost: cnf.HostConcurrency, MaxBodySize: cnf.MaxBodySize}
+	ag := aggregator.New(time.Duration(cnf.RequestPeriod)*time.Minute, limits, db)
+	ag.SetExtractor(extract.New(time.Duration(cnf.FullText.Timeout)*time.Second, cnf.FullText.PerMinute))
 	go ag.Run(ctx, postsCh, errsCh)
 
 	go func() {
@@ -56,14 +99,7 @@ func main() {
 				if !ok {
 					return
 				}
-				for _, post := range posts {
-					saved, err := db.AddPost(post)
-					if err != nil {
-						slog.Error("could not add post", "link", post.Link, "err", err)
-						continue
-					}
-					slog.Info("post added", "post", saved.ID, "link", saved.Link)
-				}
+				slog.Info("posts saved", "count", len(posts))
 			}
 		}
 	}()
@@ -107,3 +143,96 @@ func main() {
 		slog.Error("http server shutdown error", "err", err)
 	}
 }
+
+// seedFeeds fills the feeds table from the configuration on first start.
+// Once the table has feeds, it is managed through the API and the configuration is ignored.
+func seedFeeds(db storage.Storage, feeds []config.Feed) error {
+	existing, err := db.Feeds()
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	for _, f := range feeds {
+		_, err := db.AddFeed(storage.Feed{URL: f.URL, Interval: f.Interval, Enabled: true, FullText: f.FullText, MaxBodySize: f.MaxBodySize})
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info("feeds seeded from configuration", "count", len(feeds))
+	return nil
+}
+
+// importOPML merges the feeds of an OPML file into the feeds table: feeds not there yet are added,
+// known feeds only get the title and category they lack.
+func importOPML(db storage.Storage, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	list, err := opml.Parse(file)
+	if err != nil {
+		return err
+	}
+
+	feeds := make([]storage.Feed, len(list))
+	for i, f := range list {
+		feeds[i] = storage.Feed{URL: f.URL, Title: f.Title, Category: f.Category, Enabled: true}
+	}
+	res, err := db.ImportFeeds(feeds)
+	if err != nil {
+		return err
+	}
+	slog.Info("feeds imported from OPML", "path", path, "inserted", res.Inserted, "updated", res.Updated, "unchanged", res.Unchanged)
+	return nil
+}
+
+// discoverFeeds adds the first feed found for each site page from the configuration,
+// unless a feed with its URL is already there. Sites without feeds are logged and skipped.
+// Like seedFeeds it runs on first start only, so feeds deleted through the API stay deleted.
+func discoverFeeds(ctx context.Context, db storage.Storage, finder *discover.Discoverer, sites []string) error {
+	if len(sites) == 0 {
+		return nil
+	}
+
+	existing, err := db.Feeds()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, f := range existing {
+		known[f.URL] = true
+	}
+
+	for _, site := range sites {
+		feeds, err := finder.Discover(ctx, site)
+		if err != nil {
+			slog.Warn("feed discovery failed", "site", site, "err", err)
+			continue
+		}
+		if len(feeds) == 0 {
+			slog.Warn("no feeds found", "site", site)
+			continue
+		}
+
+		f := feeds[0]
+		if known[f.URL] {
+			continue
+		}
+		_, err = db.AddFeed(storage.Feed{URL: f.URL, Title: f.Title, Enabled: true})
+		if err != nil {
+			return err
+		}
+		known[f.URL] = true
+		slog.Info("discovered feed added", "site", site, "url", f.URL, "title", f.Title)
+	}
+	return nil
+}
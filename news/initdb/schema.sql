@@ -1,6 +1,25 @@
-DROP TABLE IF EXISTS posts;
+-- The schema is idempotent: it creates what is missing and upgrades an existing database in
+-- place, so it can be applied again after an update without losing stored posts.
 
-CREATE TABLE posts (
+CREATE TABLE IF NOT EXISTS feeds (
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL DEFAULT '',
+	category TEXT NOT NULL DEFAULT '',
+	interval_minutes INT NOT NULL DEFAULT 0,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	full_text BOOLEAN NOT NULL DEFAULT FALSE,
+	max_body_size BIGINT NOT NULL DEFAULT 0,
+	etag TEXT NOT NULL DEFAULT '',
+	last_modified TEXT NOT NULL DEFAULT '',
+	last_attempt TIMESTAMP,
+	last_success TIMESTAMP,
+	last_error TEXT NOT NULL DEFAULT '',
+	failures INT NOT NULL DEFAULT 0,
+	items_ingested BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS posts (
 	id BIGSERIAL PRIMARY KEY,
 	title TEXT NOT NULL CHECK (char_length(title) <= 255),
 	content TEXT NOT NULL,
@@ -8,4 +27,58 @@ CREATE TABLE posts (
 	link TEXT NOT NULL UNIQUE
 );
 
-CREATE INDEX idx_posts_pub_time ON posts(pub_time DESC);
\ No newline at end of file
+ALTER TABLE posts
+	ADD COLUMN IF NOT EXISTS content_html TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS full_content TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS author TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS guid TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS date_estimated BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS feed_id BIGINT REFERENCES feeds(id) ON DELETE SET NULL,
+	ADD COLUMN IF NOT EXISTS source_title TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS source_link TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS simhash BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS cluster_id BIGINT REFERENCES posts(id) ON DELETE SET NULL;
+
+-- Added after the columns it reads from.
+ALTER TABLE posts
+	ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+		setweight(to_tsvector('russian', title), 'A') ||
+		setweight(to_tsvector('english', title), 'A') ||
+		setweight(to_tsvector('russian', content), 'B') ||
+		setweight(to_tsvector('english', content), 'B') ||
+		setweight(to_tsvector('russian', full_content), 'C') ||
+		setweight(to_tsvector('english', full_content), 'C')
+	) STORED;
+
+-- idx_posts_pub_time used to cover pub_time alone; keyset pagination also orders by id.
+DROP INDEX IF EXISTS idx_posts_pub_time;
+CREATE INDEX IF NOT EXISTS idx_posts_pub_time_id ON posts(pub_time DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_feed_id ON posts(feed_id, pub_time DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (search_vector);
+-- Feeds may change an item's link but keep its GUID; a GUID identifies the post within its feed.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_feed_guid ON posts(feed_id, guid) WHERE guid <> '';
+CREATE INDEX IF NOT EXISTS idx_posts_cluster_id ON posts(cluster_id);
+
+CREATE TABLE IF NOT EXISTS media (
+	id BIGSERIAL PRIMARY KEY,
+	post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	type TEXT NOT NULL DEFAULT '',
+	length BIGINT NOT NULL DEFAULT 0,
+	width INT NOT NULL DEFAULT 0,
+	height INT NOT NULL DEFAULT 0,
+	UNIQUE (post_id, url)
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+	post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag_id ON post_tags(tag_id);
@@ -3,14 +3,74 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
 	"strings"
 )
 
 // Config — configuration structure
 type Config struct {
-	RSS           []string `json:"rss"`
-	RequestPeriod int      `json:"request_period"`
+	RSS             []Feed   `json:"rss"`
+	RequestPeriod   int      `json:"request_period"`
+	Concurrency     int      `json:"concurrency"`
+	HostConcurrency int      `json:"host_concurrency"`
+	FullText        FullText `json:"full_text"`
+	MaxBodySize     int64    `json:"max_body_size"`   // bytes per feed document
+	Discover        []string `json:"discover"`        // site pages whose feeds are found and added at startup
+	OPML            string   `json:"opml"`            // OPML file merged into the feeds at startup
+	PublicURL       string   `json:"public_url"`      // address readers reach the service at, for feed links
+	TrustedProxies  []string `json:"trusted_proxies"` // gateways whose X-Forwarded-* headers are believed
+
+	Proxies []netip.Prefix `json:"-"` // parsed trusted_proxies, addresses or CIDR ranges
+}
+
+// FullText — limits of the article page downloads for feeds with full text extraction.
+type FullText struct {
+	Timeout   int `json:"timeout"`    // seconds per page
+	PerMinute int `json:"per_minute"` // pages per minute across all feeds
+}
+
+// Feed — a single source with its own polling interval in minutes.
+// A zero interval falls back to request_period. FullText extracts the article
+// body of every post from its page, for feeds that only carry teasers.
+// A zero max_body_size falls back to the global one.
+type Feed struct {
+	URL         string `json:"url"`
+	Interval    int    `json:"interval"`
+	FullText    bool   `json:"full_text"`
+	MaxBodySize int64  `json:"max_body_size"`
+}
+
+// UnmarshalJSON accepts either a plain URL string or a feed object.
+func (f *Feed) UnmarshalJSON(b []byte) error {
+	var url string
+	if err := json.Unmarshal(b, &url); err == nil {
+		*f = Feed{URL: url}
+		return nil
+	}
+
+	type plain Feed
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*f = Feed(p)
+	return nil
+}
+
+// parseProxy accepts a CIDR range or a single address.
+func parseProxy(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
 }
 
 // Load reads the configuration file, parses the JSON.
@@ -25,25 +85,90 @@ func (c *Config) Load(path string) error {
 		return fmt.Errorf("deserialization error: %v", err)
 	}
 
+	if c.RequestPeriod <= 0 {
+		c.RequestPeriod = 5
+	}
+
+	if c.Concurrency <= 0 {
+		c.Concurrency = 4
+	}
+
+	if c.HostConcurrency <= 0 {
+		c.HostConcurrency = 1
+	}
+
+	if c.FullText.Timeout <= 0 {
+		c.FullText.Timeout = 10
+	}
+
+	if c.FullText.PerMinute <= 0 {
+		c.FullText.PerMinute = 30
+	}
+
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = 10 << 20
+	}
+
 	double := make(map[string]struct{}, len(c.RSS))
-	out := make([]string, 0, len(c.RSS))
+	out := make([]Feed, 0, len(c.RSS))
 	for _, raw := range c.RSS {
-		s := strings.TrimSpace(raw)
+		f := raw
+		f.URL = strings.TrimSpace(raw.URL)
+		if f.URL == "" {
+			continue
+		}
 
-		if _, ok := double[s]; !ok {
-			double[s] = struct{}{}
-			out = append(out, s)
+		if f.Interval < 0 {
+			f.Interval = 0
+		}
+
+		if f.MaxBodySize < 0 {
+			f.MaxBodySize = 0
+		}
+
+		if _, ok := double[f.URL]; !ok {
+			double[f.URL] = struct{}{}
+			out = append(out, f)
 		}
 	}
 
-	if len(out) == 0 {
-		return fmt.Errorf("rss list is empty")
+	sites := make([]string, 0, len(c.Discover))
+	for _, raw := range c.Discover {
+		site := strings.TrimSpace(raw)
+		if site == "" {
+			continue
+		}
+
+		if _, ok := double[site]; !ok {
+			double[site] = struct{}{}
+			sites = append(sites, site)
+		}
 	}
 
-	if c.RequestPeriod <= 0 {
-		c.RequestPeriod = 5
+	c.OPML = strings.TrimSpace(c.OPML)
+
+	c.PublicURL = strings.TrimSpace(c.PublicURL)
+	if c.PublicURL != "" {
+		u, err := url.Parse(c.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid public_url %q", c.PublicURL)
+		}
+	}
+
+	c.Proxies = c.Proxies[:0]
+	for _, raw := range c.TrustedProxies {
+		p, err := parseProxy(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %v", raw, err)
+		}
+		c.Proxies = append(c.Proxies, p)
+	}
+
+	if len(out) == 0 && len(sites) == 0 && c.OPML == "" {
+		return fmt.Errorf("rss list is empty")
 	}
 
 	c.RSS = out
+	c.Discover = sites
 	return nil
 }
@@ -2,8 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -72,3 +74,121 @@ func TestConfig_Load(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_LoadFeedObjects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds.json")
+	raw := `{
+		"rss": [
+			"https://habr.com/ru/rss/hub/go/all/?fl=ru",
+			{"url": "https://vc.ru/rss", "interval": 1},
+			{"url": "https://vc.ru/rss", "interval": 30},
+			{"url": "https://tproger.ru/feed", "full_text": true, "max_body_size": 1048576}
+		],
+		"request_period": 10,
+		"full_text": {"per_minute": 12}
+	}`
+
+	err := os.WriteFile(path, []byte(raw), 0644)
+	if err != nil {
+		t.Fatalf("Write test file: %v", err)
+	}
+
+	c := &Config{}
+	if err := c.Load(path); err != nil {
+		t.Fatalf("Config.Load() error = %v", err)
+	}
+
+	want := []Feed{
+		{URL: "https://habr.com/ru/rss/hub/go/all/?fl=ru"},
+		{URL: "https://vc.ru/rss", Interval: 1},
+		{URL: "https://tproger.ru/feed", FullText: true, MaxBodySize: 1 << 20},
+	}
+	if !reflect.DeepEqual(c.RSS, want) {
+		t.Errorf("Config.Load() RSS = %+v, want %+v", c.RSS, want)
+	}
+
+	wantFullText := FullText{Timeout: 10, PerMinute: 12}
+	if c.FullText != wantFullText {
+		t.Errorf("Config.Load() FullText = %+v, want %+v", c.FullText, wantFullText)
+	}
+
+	if c.MaxBodySize != 10<<20 {
+		t.Errorf("Config.Load() MaxBodySize = %d, want the 10 MiB default", c.MaxBodySize)
+	}
+}
+
+func TestConfig_LoadDiscover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "discover.json")
+	raw := `{
+		"discover": [" https://go.dev/blog/ ", "", "https://go.dev/blog/", "https://habr.com"]
+	}`
+
+	err := os.WriteFile(path, []byte(raw), 0644)
+	if err != nil {
+		t.Fatalf("Write test file: %v", err)
+	}
+
+	c := &Config{}
+	if err := c.Load(path); err != nil {
+		t.Fatalf("Config.Load() error = %v, want sites to replace the rss list", err)
+	}
+
+	want := []string{"https://go.dev/blog/", "https://habr.com"}
+	if !reflect.DeepEqual(c.Discover, want) {
+		t.Errorf("Config.Load() Discover = %q, want %q", c.Discover, want)
+	}
+	if len(c.RSS) != 0 {
+		t.Errorf("Config.Load() RSS = %+v, want empty", c.RSS)
+	}
+}
+
+func TestConfig_LoadOPML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "opml.json")
+
+	err := os.WriteFile(path, []byte(`{"opml": " ./feeds.opml "}`), 0644)
+	if err != nil {
+		t.Fatalf("Write test file: %v", err)
+	}
+
+	c := &Config{}
+	if err := c.Load(path); err != nil {
+		t.Fatalf("Config.Load() error = %v, want an OPML file to replace the rss list", err)
+	}
+	if c.OPML != "./feeds.opml" {
+		t.Errorf("Config.Load() OPML = %q, want %q", c.OPML, "./feeds.opml")
+	}
+}
+
+func TestConfig_LoadPublicAddress(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatalf("Write test file: %v", err)
+		}
+		return path
+	}
+
+	path := write("valid.json", `{"rss": ["https://example.com/rss"], "public_url": " https://news.example.com ", "trusted_proxies": ["10.0.0.7", "172.16.0.0/12"]}`)
+	c := &Config{}
+	if err := c.Load(path); err != nil {
+		t.Fatalf("Config.Load() error = %v", err)
+	}
+	if c.PublicURL != "https://news.example.com" {
+		t.Errorf("Config.Load() PublicURL = %q, want %q", c.PublicURL, "https://news.example.com")
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.7/32"), netip.MustParsePrefix("172.16.0.0/12")}
+	if !reflect.DeepEqual(c.Proxies, want) {
+		t.Errorf("Config.Load() Proxies = %v, want %v", c.Proxies, want)
+	}
+
+	for name, body := range map[string]string{
+		"relative.json": `{"rss": ["https://example.com/rss"], "public_url": "news.example.com"}`,
+		"proxy.json":    `{"rss": ["https://example.com/rss"], "trusted_proxies": ["gateway"]}`,
+	} {
+		c := &Config{}
+		if err := c.Load(write(name, body)); err == nil {
+			t.Errorf("Config.Load(%s) error = nil, want error", name)
+		}
+	}
+}
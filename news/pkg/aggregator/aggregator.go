@@ -2,44 +2,372 @@ package aggregator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"news/pkg/netguard"
 	"news/pkg/rss"
 	"news/pkg/storage"
+	"strings"
+	"sync"
 	"time"
 )
 
+// extractQueueSize bounds how many posts wait for their full text at the same time.
+const extractQueueSize = 256
+
+// extractRetry is how long a link queued for extraction is not queued again.
+const extractRetry = 24 * time.Hour
+
+// Store provides the list of feeds to poll, stores their posts and persists their polling
+// state and health.
+type Store interface {
+	Feeds() ([]storage.Feed, error)
+	UpsertPosts(posts []storage.Post) (storage.UpsertResult, error)
+	FeedState(url string) (storage.FeedState, error)
+	SaveFeedState(s storage.FeedState) error
+	RecordFetch(url string, items int, fetchErr error) error
+	FullContentLinks(links []string) (map[string]bool, error)
+}
+
+// Extractor downloads an article page and returns its main text.
+type Extractor interface {
+	Extract(ctx context.Context, url string) (string, error)
+}
+
+// Limits bounds how many feeds are fetched at the same time and how large they may be.
+type Limits struct {
+	Workers     int   // concurrent fetches overall
+	PerHost     int   // concurrent fetches against a single host
+	MaxBodySize int64 // bytes per feed document unless the feed sets its own
+}
+
 // Aggregator polls RSS feeds and stores posts in the storage.
+// The feed list is re-read from the store on every tick, so feeds added,
+// changed or removed at runtime are picked up without a restart.
+// Feed URLs come from users, so feeds are only fetched from public addresses.
 type Aggregator struct {
-	feeds  []string
-	period time.Duration
-	client *http.Client
+	period    time.Duration
+	limits    Limits
+	client    *http.Client
+	store     Store
+	extractor Extractor
+	extracts  chan storage.Post // posts waiting for their full text
+
+	mu        sync.Mutex
+	schedules map[string]*schedule
+	attempted map[string]time.Time // links queued for extraction, by when
 }
 
 // New creates and initializes a new Aggregator.
-func New(f []string, p time.Duration) *Aggregator {
+// Non-positive limits default to 4 workers, 1 fetch per host and rss.DefaultMaxBodySize.
+func New(p time.Duration, l Limits, s Store) *Aggregator {
+	if l.Workers <= 0 {
+		l.Workers = 4
+	}
+	if l.PerHost <= 0 {
+		l.PerHost = 1
+	}
+	if l.MaxBodySize <= 0 {
+		l.MaxBodySize = rss.DefaultMaxBodySize
+	}
+
 	ag := Aggregator{
-		feeds:  f,
-		period: p,
-		client: &http.Client{Timeout: 10 * time.Second},
+		period:    p,
+		limits:    l,
+		client:    netguard.Client(10 * time.Second),
+		store:     s,
+		schedules: make(map[string]*schedule),
+		attempted: make(map[string]time.Time),
 	}
 	return &ag
 }
 
-// runOnce fetches all feeds once and sends posts to channels.
+// SetExtractor enables full text extraction for feeds that ask for it.
+// It must be called before Run.
+func (a *Aggregator) SetExtractor(e Extractor) {
+	a.extractor = e
+	a.extracts = make(chan storage.Post, extractQueueSize)
+}
+
+// runOnce fetches all enabled feeds once regardless of their schedule and sends posts to channels.
 func (a *Aggregator) runOnce(ctx context.Context, posts chan<- []storage.Post, errs chan<- error) {
-	for _, url := range a.feeds {
+	feeds, err := a.enabledFeeds()
+	if err != nil {
+		sendErr(ctx, errs, err)
+		return
+	}
+	a.poll(ctx, feeds, posts, errs)
+}
+
+// runDue fetches only the enabled feeds whose next poll time has come.
+func (a *Aggregator) runDue(ctx context.Context, posts chan<- []storage.Post, errs chan<- error) {
+	feeds, err := a.enabledFeeds()
+	if err != nil {
+		sendErr(ctx, errs, err)
+		return
+	}
+
+	now := time.Now()
+
+	a.mu.Lock()
+	known := make(map[string]*schedule, len(feeds))
+	var due []storage.Feed
+	for _, f := range feeds {
+		s := a.scheduleFor(f.URL)
+		known[f.URL] = s
+		if s.due(now) {
+			due = append(due, f)
+		}
+	}
+	a.schedules = known
+	a.mu.Unlock()
+
+	a.poll(ctx, due, posts, errs)
+}
+
+// enabledFeeds loads the current feed list from the store and drops disabled feeds.
+func (a *Aggregator) enabledFeeds() ([]storage.Feed, error) {
+	all, err := a.store.Feeds()
+	if err != nil {
+		return nil, err
+	}
+
+	feeds := make([]storage.Feed, 0, len(all))
+	for _, f := range all {
+		if f.Enabled {
+			feeds = append(feeds, f)
+		}
+	}
+	return feeds, nil
+}
+
+// poll fetches the feeds through a bounded worker pool.
+// Feeds are handed to the workers only while their host has fewer than PerHost fetches in
+// flight, so feeds sharing a domain are not fetched all at once and wait in line without
+// holding a worker that feeds of other hosts could use.
+func (a *Aggregator) poll(ctx context.Context, feeds []storage.Feed, posts chan<- []storage.Post, errs chan<- error) {
+	jobs := make(chan storage.Feed)
+	finished := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < a.limits.Workers && i < len(feeds); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				a.fetch(ctx, f, posts, errs)
+				finished <- host(f.URL)
+			}
+		}()
+	}
+
+	pending := append([]storage.Feed(nil), feeds...)
+	active := make(map[string]int)
+	running := 0
+	done := ctx.Done()
+	for len(pending) > 0 || running > 0 {
+		// The first pending feed whose host has room goes next; jobs stays nil while there
+		// is none, so the loop only waits for a running fetch to finish.
+		var out chan storage.Feed
+		next := -1
+		for i, f := range pending {
+			if active[host(f.URL)] < a.limits.PerHost {
+				out, next = jobs, i
+				break
+			}
+		}
+
+		var job storage.Feed
+		if next >= 0 {
+			job = pending[next]
+		}
 		select {
-		case <-ctx.Done():
+		case <-done:
+			pending, done = nil, nil
+		case out <- job:
+			pending = append(pending[:next], pending[next+1:]...)
+			active[host(job.URL)]++
+			running++
+		case h := <-finished:
+			active[h]--
+			running--
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// fetch polls a single feed, stores its posts, sends them or the error to the channels and plans
+// the next poll. Posts are attributed to the feed; a feed title set through the API wins over
+// the channel title. Feeds that respond with 304 Not Modified produce no posts.
+func (a *Aggregator) fetch(ctx context.Context, f storage.Feed, posts chan<- []storage.Post, errs chan<- error) {
+	state, err := a.store.FeedState(f.URL)
+	if err != nil {
+		if !sendErr(ctx, errs, err) {
+			return
+		}
+		state = storage.FeedState{URL: f.URL}
+	}
+
+	maxBody := f.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = a.limits.MaxBodySize
+	}
+	res, err := rss.Fetch(ctx, a.client, f.URL, rss.Validators{
+		ETag:         state.ETag,
+		LastModified: state.LastModified,
+	}, maxBody)
+
+	a.mu.Lock()
+	if err != nil {
+		a.scheduleFor(f.URL).failed(time.Now(), a.interval(f), res.RetryAfter)
+	} else {
+		a.scheduleFor(f.URL).succeeded(time.Now(), a.interval(f), res)
+	}
+	a.mu.Unlock()
+
+	if err != nil || res.NotModified {
+		recErr := a.store.RecordFetch(f.URL, 0, err)
+		if recErr != nil && !sendErr(ctx, errs, recErr) {
 			return
+		}
+		if err != nil {
+			sendErr(ctx, errs, err)
+		}
+		return
+	}
+
+	src := storage.Source{FeedID: f.ID, Title: f.Title, Link: res.Link}
+	if src.Title == "" {
+		src.Title = res.Title
+	}
+	for i := range res.Posts {
+		res.Posts[i].Source = src
+	}
+
+	var validators *storage.FeedState
+	if res.Validators.ETag != state.ETag || res.Validators.LastModified != state.LastModified {
+		state.ETag = res.Validators.ETag
+		state.LastModified = res.Validators.LastModified
+		validators = &state
+	}
+	if !a.save(ctx, f.URL, res.Posts, validators, errs) {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	case posts <- res.Posts:
+	}
+
+	// Queued after the posts are stored, so the extracted text is not overwritten by them.
+	if f.FullText && a.extractor != nil {
+		a.queueExtraction(ctx, res.Posts, errs)
+	}
+}
+
+// save stores the polled posts of a feed, records the poll with the number of posts actually
+// inserted and then saves the cache validators of the feed, if they changed. The validators
+// are only remembered once the posts are stored, so posts that failed to save are fetched
+// again instead of being skipped as not modified. It reports whether the posts were stored;
+// posts skipped by the store do not fail the poll.
+func (a *Aggregator) save(ctx context.Context, feedURL string, posts []storage.Post, validators *storage.FeedState, errs chan<- error) bool {
+	res, err := a.store.UpsertPosts(posts)
+	if err != nil && !errors.Is(err, storage.ErrSkipped) {
+		if recErr := a.store.RecordFetch(feedURL, 0, err); recErr != nil {
+			err = errors.Join(err, recErr)
+		}
+		sendErr(ctx, errs, fmt.Errorf("failed to save posts of %s: %w", feedURL, err))
+		return false
+	}
+	if err != nil && !sendErr(ctx, errs, fmt.Errorf("some posts of %s were not saved: %w", feedURL, err)) {
+		return false
+	}
+
+	err = a.store.RecordFetch(feedURL, res.Inserted, nil)
+	if err != nil && !sendErr(ctx, errs, err) {
+		return false
+	}
+
+	if validators != nil {
+		err = a.store.SaveFeedState(*validators)
+		if err != nil && !sendErr(ctx, errs, err) {
+			return false
+		}
+	}
+	return true
+}
+
+// queueExtraction queues the posts whose article has not been extracted yet for extractQueued.
+// Links are queued once and then left alone for extractRetry, whether or not their article
+// could be extracted, so failing pages are not downloaded on every poll. When the queue is
+// full the remaining posts wait for the next poll of their feed.
+func (a *Aggregator) queueExtraction(ctx context.Context, posts []storage.Post, errs chan<- error) {
+	links := make([]string, len(posts))
+	for i, p := range posts {
+		links[i] = p.Link
+	}
+
+	done, err := a.store.FullContentLinks(links)
+	if err != nil {
+		sendErr(ctx, errs, err)
+		return
+	}
+
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for l, at := range a.attempted {
+		if now.Sub(at) >= extractRetry {
+			delete(a.attempted, l)
+		}
+	}
+
+	for _, p := range posts {
+		if done[p.Link] {
+			continue
+		}
+		if _, ok := a.attempted[p.Link]; ok {
+			continue
+		}
+
+		select {
+		case a.extracts <- p:
+			a.attempted[p.Link] = now
 		default:
+			return
+		}
+	}
+}
+
+// extractQueued extracts the full text of queued posts one at a time, apart from the polling
+// workers, stores every post that got it and sends it on its own. A post whose page cannot
+// be extracted keeps its feed content. It returns when the context is canceled.
+func (a *Aggregator) extractQueued(ctx context.Context, posts chan<- []storage.Post, errs chan<- error) {
+	for {
+		var p storage.Post
+		select {
+		case <-ctx.Done():
+			return
+		case p = <-a.extracts:
+		}
+
+		text, err := a.extractor.Extract(ctx, p.Link)
+		if err != nil {
+			if ctx.Err() != nil || !sendErr(ctx, errs, fmt.Errorf("failed to extract full text of %s: %w", p.Link, err)) {
+				return
+			}
+			continue
 		}
+		p.FullContent = text
 
-		news, err := rss.Parse(ctx, a.client, url)
+		stored := []storage.Post{p}
+		_, err = a.store.UpsertPosts(stored)
 		if err != nil {
-			select {
-			case <-ctx.Done():
+			if !sendErr(ctx, errs, fmt.Errorf("failed to save full text of %s: %w", p.Link, err)) {
 				return
-			case errs <- err:
 			}
 			continue
 		}
@@ -47,19 +375,70 @@ func (a *Aggregator) runOnce(ctx context.Context, posts chan<- []storage.Post, e
 		select {
 		case <-ctx.Done():
 			return
-		case posts <- news:
+		case posts <- stored:
 		}
 	}
 }
 
-// Run starts periodic fetching until the context is canceled.
+// interval returns the polling interval of the feed, falling back to the aggregator period.
+func (a *Aggregator) interval(f storage.Feed) time.Duration {
+	if f.Interval > 0 {
+		return time.Duration(f.Interval) * time.Minute
+	}
+	return a.period
+}
+
+// scheduleFor returns the schedule of the feed, creating a due one on first use.
+// The caller must hold a.mu.
+func (a *Aggregator) scheduleFor(feedURL string) *schedule {
+	s, ok := a.schedules[feedURL]
+	if !ok {
+		s = &schedule{}
+		a.schedules[feedURL] = s
+	}
+	return s
+}
+
+// tick returns how often due feeds are checked: the period, but at least once a minute.
+func (a *Aggregator) tick() time.Duration {
+	if a.period < time.Minute {
+		return a.period
+	}
+	return time.Minute
+}
+
+// host returns the host part of the feed URL used as the per-host limit key.
+func host(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return feedURL
+	}
+	return strings.ToLower(u.Host)
+}
+
+// sendErr delivers err to the errs channel and reports false if the context was canceled first.
+func sendErr(ctx context.Context, errs chan<- error, err error) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case errs <- err:
+		return true
+	}
+}
+
+// Run polls every feed once and then keeps polling each feed on its own schedule
+// until the context is canceled.
 func (a *Aggregator) Run(ctx context.Context, posts chan<- []storage.Post, errs chan<- error) {
+	if a.extractor != nil {
+		go a.extractQueued(ctx, posts, errs)
+	}
+
 	if a.period <= 0 {
 		a.runOnce(ctx, posts, errs)
 		return
 	}
 
-	ticker := time.NewTicker(a.period)
+	ticker := time.NewTicker(a.tick())
 
 	a.runOnce(ctx, posts, errs)
 
@@ -68,7 +447,7 @@ func (a *Aggregator) Run(ctx context.Context, posts chan<- []storage.Post, errs
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			a.runOnce(ctx, posts, errs)
+			a.runDue(ctx, posts, errs)
 		}
 	}
 }
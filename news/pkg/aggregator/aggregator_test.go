@@ -2,9 +2,13 @@ package aggregator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"news/pkg/netguard"
 	"news/pkg/storage"
+	"sync"
 	"testing"
 	"time"
 )
@@ -35,6 +39,124 @@ const rssXML = `<rss version="2.0">
     </channel>
     </rss>`
 
+// memStore is an in-memory Store for tests.
+type memStore struct {
+	mu        sync.Mutex
+	feeds     []storage.Feed
+	states    map[string]storage.FeedState
+	full      map[string]bool
+	posts     []storage.Post
+	upsertErr error
+	ingested  map[string]int
+	failures  map[string]int
+}
+
+func newMemStore(urls ...string) *memStore {
+	m := &memStore{
+		states:   make(map[string]storage.FeedState),
+		ingested: make(map[string]int),
+		failures: make(map[string]int),
+	}
+	for i, u := range urls {
+		m.feeds = append(m.feeds, storage.Feed{ID: i + 1, URL: u, Enabled: true})
+	}
+	return m
+}
+
+func (m *memStore) Feeds() ([]storage.Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]storage.Feed(nil), m.feeds...), nil
+}
+
+func (m *memStore) UpsertPosts(posts []storage.Post) (storage.UpsertResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.upsertErr != nil {
+		return storage.UpsertResult{}, m.upsertErr
+	}
+	var res storage.UpsertResult
+next:
+	for _, p := range posts {
+		for _, old := range m.posts {
+			if old.Link == p.Link {
+				res.Unchanged++
+				continue next
+			}
+		}
+		m.posts = append(m.posts, p)
+		res.Inserted++
+	}
+	return res, nil
+}
+
+func (m *memStore) FeedState(url string) (storage.FeedState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.states[url]
+	if !ok {
+		s.URL = url
+	}
+	return s, nil
+}
+
+func (m *memStore) SaveFeedState(s storage.FeedState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[s.URL] = s
+	return nil
+}
+
+func (m *memStore) RecordFetch(url string, items int, fetchErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if fetchErr != nil {
+		m.failures[url]++
+		return nil
+	}
+	m.failures[url] = 0
+	m.ingested[url] += items
+	return nil
+}
+
+func (m *memStore) FullContentLinks(links []string) (map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	done := make(map[string]bool)
+	for _, l := range links {
+		if m.full[l] {
+			done[l] = true
+		}
+	}
+	return done, nil
+}
+
+// newTestAggregator creates an Aggregator that may fetch the feeds of local test servers,
+// which the public-address check of New refuses.
+func newTestAggregator(p time.Duration, l Limits, s Store) *Aggregator {
+	a := New(p, l, s)
+	a.client = &http.Client{Timeout: 10 * time.Second}
+	return a
+}
+
+// stubExtractor returns the article text by link and fails for unknown links.
+type stubExtractor struct {
+	mu    sync.Mutex
+	texts map[string]string
+	calls []string
+}
+
+func (e *stubExtractor) Extract(ctx context.Context, url string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, url)
+	text, ok := e.texts[url]
+	if !ok {
+		return "", errors.New("no article")
+	}
+	return text, nil
+}
+
 func TestAggregator_runOnce(t *testing.T) {
 	t.Run("Valid Test", func(t *testing.T) {
 		tsOK := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -43,7 +165,7 @@ func TestAggregator_runOnce(t *testing.T) {
 		}))
 		defer tsOK.Close()
 
-		ag := New([]string{tsOK.URL}, 0)
+		ag := newTestAggregator(0, Limits{}, newMemStore(tsOK.URL))
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
@@ -61,6 +183,11 @@ func TestAggregator_runOnce(t *testing.T) {
 			if got[0].Title != "Test Title #1" || got[1].Title != "Test Title #2" {
 				t.Fatalf("unexpected titles: %v", got)
 			}
+
+			want := storage.Source{FeedID: 1, Title: "Demo"}
+			if got[0].Source != want {
+				t.Fatalf("got source %+v - want %+v", got[0].Source, want)
+			}
 		case err := <-errsCh:
 			t.Fatalf("unexpected error: %v", err)
 		case <-time.After(1 * time.Second):
@@ -75,7 +202,7 @@ func TestAggregator_runOnce(t *testing.T) {
 		}))
 		defer tsOK.Close()
 
-		ag := New([]string{tsOK.URL}, 0)
+		ag := newTestAggregator(0, Limits{}, newMemStore(tsOK.URL))
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
@@ -95,6 +222,34 @@ func TestAggregator_runOnce(t *testing.T) {
 			t.Fatalf("timeout waiting for error")
 		}
 	})
+
+	t.Run("Private Address", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(rssXML))
+		}))
+		defer ts.Close()
+
+		store := newMemStore(ts.URL)
+		ag := New(0, Limits{}, store)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		postCh := make(chan []storage.Post, 1)
+		errsCh := make(chan error, 1)
+
+		ag.runOnce(ctx, postCh, errsCh)
+
+		if len(postCh) != 0 {
+			t.Fatalf("unexpected posts from a loopback feed")
+		}
+		if err := <-errsCh; !errors.Is(err, netguard.ErrPrivateAddress) {
+			t.Fatalf("got %v - want netguard.ErrPrivateAddress", err)
+		}
+		if store.failures[ts.URL] != 1 {
+			t.Fatalf("got %d failures - want the refused fetch recorded", store.failures[ts.URL])
+		}
+	})
 }
 
 func TestAggregator_Run(t *testing.T) {
@@ -106,7 +261,7 @@ func TestAggregator_Run(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		ag := New([]string{ts.URL}, 20*time.Millisecond)
+		ag := newTestAggregator(20*time.Millisecond, Limits{}, newMemStore(ts.URL))
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
@@ -138,7 +293,7 @@ func TestAggregator_Run(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		ag := New([]string{ts.URL}, 0)
+		ag := newTestAggregator(0, Limits{}, newMemStore(ts.URL))
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
@@ -163,3 +318,405 @@ func TestAggregator_Run(t *testing.T) {
 		}
 	})
 }
+
+func TestAggregator_runOnceNotModified(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rssXML))
+	}))
+	defer ts.Close()
+
+	ag := newTestAggregator(0, Limits{}, newMemStore(ts.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	postCh := make(chan []storage.Post)
+	errsCh := make(chan error)
+
+	go ag.runOnce(ctx, postCh, errsCh)
+
+	select {
+	case got := <-postCh:
+		if len(got) != 2 {
+			t.Fatalf("got %d - want 2 posts", len(got))
+		}
+	case err := <-errsCh:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("timeout waiting for posts")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ag.runOnce(ctx, postCh, errsCh)
+		close(done)
+	}()
+
+	select {
+	case <-postCh:
+		t.Fatalf("unexpected posts on 304 Not Modified")
+	case err := <-errsCh:
+		t.Fatalf("unexpected error: %v", err)
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("timeout waiting for second run")
+	}
+}
+
+func TestAggregator_runOnceSave(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rssXML))
+	}))
+	defer ts.Close()
+
+	store := newMemStore(ts.URL)
+	ag := newTestAggregator(0, Limits{}, store)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	poll := func() ([]storage.Post, error) {
+		postCh := make(chan []storage.Post, 1)
+		errsCh := make(chan error, 1)
+		ag.runOnce(ctx, postCh, errsCh)
+		select {
+		case err := <-errsCh:
+			return nil, err
+		default:
+		}
+		select {
+		case posts := <-postCh:
+			return posts, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	store.upsertErr = errors.New("db is down")
+	if _, err := poll(); err == nil {
+		t.Fatalf("got nil - want error")
+	}
+	if s, _ := store.FeedState(ts.URL); s.ETag != "" {
+		t.Fatalf("got ETag %q saved - want none after a failed upsert", s.ETag)
+	}
+	if store.failures[ts.URL] != 1 {
+		t.Fatalf("got %d failures - want the failed upsert recorded", store.failures[ts.URL])
+	}
+
+	// The posts were not stored, so the next poll must fetch them again.
+	store.upsertErr = nil
+	posts, err := poll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts - want the feed again after a failed upsert", len(posts))
+	}
+	if len(store.posts) != 2 || store.ingested[ts.URL] != 2 {
+		t.Fatalf("got %d posts stored and %d ingested - want 2", len(store.posts), store.ingested[ts.URL])
+	}
+	if store.failures[ts.URL] != 0 {
+		t.Fatalf("got %d failures - want the poll recorded as successful", store.failures[ts.URL])
+	}
+	if s, _ := store.FeedState(ts.URL); s.ETag != `"v1"` {
+		t.Fatalf("got ETag %q - want %q", s.ETag, `"v1"`)
+	}
+
+	posts, err = poll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if posts != nil {
+		t.Fatalf("unexpected posts on 304 Not Modified")
+	}
+}
+
+func TestAggregator_saveSkipped(t *testing.T) {
+	const feedURL = "https://example.com/rss"
+	store := newMemStore(feedURL)
+	store.upsertErr = fmt.Errorf("%w: title too long", storage.ErrSkipped)
+	ag := newTestAggregator(0, Limits{}, store)
+
+	errsCh := make(chan error, 1)
+	validators := &storage.FeedState{URL: feedURL, ETag: `"v2"`}
+	if !ag.save(context.Background(), feedURL, nil, validators, errsCh) {
+		t.Fatalf("got false - want skipped posts not to fail the save")
+	}
+	if err := <-errsCh; !errors.Is(err, storage.ErrSkipped) {
+		t.Fatalf("got %v - want storage.ErrSkipped", err)
+	}
+	if store.failures[feedURL] != 0 {
+		t.Fatalf("got %d failures - want skipped posts not to fail the poll", store.failures[feedURL])
+	}
+	if s, _ := store.FeedState(feedURL); s.ETag != `"v2"` {
+		t.Fatalf("got ETag %q - want the validators saved", s.ETag)
+	}
+}
+
+func TestAggregator_runOnceFullText(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rssXML))
+	}))
+	defer ts.Close()
+
+	const first = "https://example.ru/ru/articles/108175/=rss"
+	const second = "https://example.ru/com/articles/1974014/=rss"
+
+	store := newMemStore(ts.URL)
+	store.feeds[0].FullText = true
+	store.full = map[string]bool{second: true}
+
+	ext := &stubExtractor{texts: map[string]string{first: "Full article #1"}}
+	ag := newTestAggregator(0, Limits{}, store)
+	ag.SetExtractor(ext)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	postCh := make(chan []storage.Post)
+	errsCh := make(chan error)
+
+	go ag.extractQueued(ctx, postCh, errsCh)
+	go ag.runOnce(ctx, postCh, errsCh)
+
+	// The polled posts come first, without waiting for the extraction.
+	select {
+	case got := <-postCh:
+		if len(got) != 2 {
+			t.Fatalf("got %d - want 2 posts of the feed", len(got))
+		}
+		if got[0].FullContent != "" || got[1].FullContent != "" {
+			t.Fatalf("unexpected full content: %+v", got)
+		}
+	case err := <-errsCh:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("timeout waiting for posts")
+	}
+
+	select {
+	case got := <-postCh:
+		if len(got) != 1 {
+			t.Fatalf("got %d - want 1 extracted post", len(got))
+		}
+		if got[0].Link != first || got[0].FullContent != "Full article #1" {
+			t.Fatalf("got %s with full content %q - want the extracted article", got[0].Link, got[0].FullContent)
+		}
+	case err := <-errsCh:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("timeout waiting for the extracted post")
+	}
+
+	if len(ext.calls) != 1 || ext.calls[0] != first {
+		t.Fatalf("got extractions %v - want only %s", ext.calls, first)
+	}
+
+	t.Run("Extraction Error", func(t *testing.T) {
+		store.full = nil
+		ext.texts = nil
+
+		// Only the link not attempted yet is extracted, and its failure is remembered.
+		for run := 0; run < 2; run++ {
+			done := make(chan struct{})
+			go func() {
+				ag.runOnce(ctx, postCh, errsCh)
+				close(done)
+			}()
+
+			select {
+			case <-postCh:
+			case err := <-errsCh:
+				t.Fatalf("unexpected error: %v", err)
+			case <-time.After(1 * time.Second):
+				t.Fatalf("timeout waiting for posts")
+			}
+			<-done
+
+			if run == 0 {
+				select {
+				case err := <-errsCh:
+					if err == nil {
+						t.Fatalf("got nil - want error")
+					}
+				case got := <-postCh:
+					t.Fatalf("unexpected extracted posts: %+v", got)
+				case <-time.After(1 * time.Second):
+					t.Fatalf("timeout waiting for the extraction error")
+				}
+			}
+		}
+
+		ext.mu.Lock()
+		defer ext.mu.Unlock()
+		if len(ext.calls) != 2 || ext.calls[1] != second {
+			t.Fatalf("got extractions %v - want %s once more", ext.calls, second)
+		}
+	})
+}
+
+func TestAggregator_runOnceConcurrency(t *testing.T) {
+	t.Run("Parallel Feeds", func(t *testing.T) {
+		var feeds []string
+		for i := 0; i < 4; i++ {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(rssXML))
+			}))
+			defer ts.Close()
+			feeds = append(feeds, ts.URL)
+		}
+
+		ag := newTestAggregator(0, Limits{Workers: 4, PerHost: 1}, newMemStore(feeds...))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		postCh := make(chan []storage.Post, len(feeds))
+		errsCh := make(chan error, len(feeds))
+
+		start := time.Now()
+		ag.runOnce(ctx, postCh, errsCh)
+		elapsed := time.Since(start)
+
+		if len(postCh) != len(feeds) {
+			t.Fatalf("got %d - want %d post batches", len(postCh), len(feeds))
+		}
+		if elapsed >= 600*time.Millisecond {
+			t.Errorf("feeds were not fetched in parallel: took %v", elapsed)
+		}
+	})
+
+	t.Run("Per Host Limit", func(t *testing.T) {
+		var mu sync.Mutex
+		var inFlight, maxInFlight int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(rssXML))
+		}))
+		defer ts.Close()
+
+		feeds := []string{ts.URL + "/a", ts.URL + "/b", ts.URL + "/c"}
+		ag := newTestAggregator(0, Limits{Workers: 3, PerHost: 1}, newMemStore(feeds...))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		postCh := make(chan []storage.Post, len(feeds))
+		errsCh := make(chan error, len(feeds))
+
+		ag.runOnce(ctx, postCh, errsCh)
+
+		if len(postCh) != len(feeds) {
+			t.Fatalf("got %d - want %d post batches", len(postCh), len(feeds))
+		}
+		if maxInFlight != 1 {
+			t.Errorf("got %d concurrent requests to one host - want 1", maxInFlight)
+		}
+	})
+
+	t.Run("Busy Host", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(rssXML))
+		}))
+		defer slow.Close()
+
+		fetched := make(chan time.Time, 1)
+		fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fetched <- time.Now()
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(rssXML))
+		}))
+		defer fast.Close()
+
+		// The feeds of the busy host come first and would take every worker if they
+		// waited for their host while holding one.
+		feeds := []string{slow.URL + "/a", slow.URL + "/b", slow.URL + "/c", fast.URL}
+		ag := newTestAggregator(0, Limits{Workers: 2, PerHost: 1}, newMemStore(feeds...))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		postCh := make(chan []storage.Post, len(feeds))
+		errsCh := make(chan error, len(feeds))
+
+		start := time.Now()
+		ag.runOnce(ctx, postCh, errsCh)
+
+		if len(postCh) != len(feeds) {
+			t.Fatalf("got %d - want %d post batches", len(postCh), len(feeds))
+		}
+		if waited := (<-fetched).Sub(start); waited >= 150*time.Millisecond {
+			t.Errorf("feed of another host waited %v for the busy host", waited)
+		}
+	})
+}
+
+func TestAggregator_runDueFeedChanges(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rssXML))
+	}))
+	defer ts.Close()
+
+	store := newMemStore(ts.URL + "/a")
+	ag := newTestAggregator(time.Hour, Limits{}, store)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	postCh := make(chan []storage.Post, 10)
+	errsCh := make(chan error, 10)
+
+	ag.runDue(ctx, postCh, errsCh)
+	ag.runDue(ctx, postCh, errsCh)
+
+	store.mu.Lock()
+	store.feeds = append(store.feeds,
+		storage.Feed{ID: 2, URL: ts.URL + "/b", Enabled: true},
+		storage.Feed{ID: 3, URL: ts.URL + "/c", Enabled: false},
+	)
+	store.mu.Unlock()
+
+	ag.runDue(ctx, postCh, errsCh)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/a"] != 1 {
+		t.Errorf("got %d polls of /a - want 1 within its interval", hits["/a"])
+	}
+	if hits["/b"] != 1 {
+		t.Errorf("got %d polls of added feed /b - want 1", hits["/b"])
+	}
+	if hits["/c"] != 0 {
+		t.Errorf("got %d polls of disabled feed /c - want 0", hits["/c"])
+	}
+}
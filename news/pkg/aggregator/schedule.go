@@ -0,0 +1,70 @@
+package aggregator
+
+import (
+	"news/pkg/rss"
+	"slices"
+	"time"
+)
+
+// maxBackoff caps the delay between polls of a feed that keeps failing.
+const maxBackoff = 24 * time.Hour
+
+// schedule tracks when a feed is due next and the polling hints it last published.
+type schedule struct {
+	next      time.Time
+	failures  int
+	ttl       time.Duration
+	skipHours []int
+	skipDays  []time.Weekday
+}
+
+// due reports whether the feed should be polled at now.
+func (s *schedule) due(now time.Time) bool {
+	return !now.Before(s.next)
+}
+
+// succeeded records a successful poll and plans the next one.
+// The feed's TTL stretches the interval and skipHours/skipDays push the poll past quiet periods.
+func (s *schedule) succeeded(now time.Time, interval time.Duration, res rss.Result) {
+	if !res.NotModified {
+		s.ttl = res.TTL
+		s.skipHours = res.SkipHours
+		s.skipDays = res.SkipDays
+	}
+	s.failures = 0
+
+	wait := interval
+	if s.ttl > wait {
+		wait = s.ttl
+	}
+	s.next = s.skip(now.Add(wait))
+}
+
+// failed records a failed poll and backs off exponentially, honoring Retry-After.
+func (s *schedule) failed(now time.Time, interval time.Duration, retryAfter time.Duration) {
+	s.failures++
+
+	wait := interval
+	for i := 0; i < s.failures && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	s.next = now.Add(wait)
+}
+
+// skip moves t forward hour by hour until it leaves the feed's skipHours and skipDays.
+func (s *schedule) skip(t time.Time) time.Time {
+	for i := 0; i < 8*24; i++ {
+		u := t.UTC()
+		if !slices.Contains(s.skipHours, u.Hour()) && !slices.Contains(s.skipDays, u.Weekday()) {
+			return t
+		}
+		t = u.Truncate(time.Hour).Add(time.Hour)
+	}
+	return t
+}
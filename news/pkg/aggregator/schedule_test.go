@@ -0,0 +1,80 @@
+package aggregator
+
+import (
+	"news/pkg/rss"
+	"testing"
+	"time"
+)
+
+func Test_schedule_succeeded(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC) // Friday
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		res      rss.Result
+		want     time.Time
+	}{
+		{
+			name:     "Interval",
+			interval: 5 * time.Minute,
+			res:      rss.Result{},
+			want:     now.Add(5 * time.Minute),
+		},
+		{
+			name:     "TTL longer than interval",
+			interval: 5 * time.Minute,
+			res:      rss.Result{TTL: time.Hour},
+			want:     now.Add(time.Hour),
+		},
+		{
+			name:     "Skip hours",
+			interval: 5 * time.Minute,
+			res:      rss.Result{SkipHours: []int{10, 11}},
+			want:     time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Skip days",
+			interval: time.Hour,
+			res:      rss.Result{SkipDays: []time.Weekday{time.Friday, time.Saturday}},
+			want:     time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &schedule{failures: 3}
+			s.succeeded(now, tt.interval, tt.res)
+			if !s.next.Equal(tt.want) {
+				t.Errorf("next = %v, want %v", s.next, tt.want)
+			}
+			if s.failures != 0 {
+				t.Errorf("failures = %d, want 0", s.failures)
+			}
+		})
+	}
+}
+
+func Test_schedule_failed(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	s := &schedule{}
+
+	for i, want := range []time.Duration{10 * time.Minute, 20 * time.Minute, 40 * time.Minute} {
+		s.failed(now, 5*time.Minute, 0)
+		if got := s.next.Sub(now); got != want {
+			t.Errorf("failure %d: wait = %v, want %v", i+1, got, want)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		s.failed(now, 5*time.Minute, 0)
+	}
+	if got := s.next.Sub(now); got != maxBackoff {
+		t.Errorf("wait = %v, want cap %v", got, maxBackoff)
+	}
+
+	s = &schedule{}
+	s.failed(now, 5*time.Minute, 2*time.Hour)
+	if got := s.next.Sub(now); got != 2*time.Hour {
+		t.Errorf("wait = %v, want Retry-After 2h", got)
+	}
+}
@@ -1,21 +1,41 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"news/pkg/discover"
 	"news/pkg/storage"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4"
 )
 
+// Discoverer finds the feeds of a website from the address of one of its pages.
+type Discoverer interface {
+	Discover(ctx context.Context, pageURL string) ([]discover.Feed, error)
+}
+
+// maxDiscoveries bounds how many feed discoveries run at the same time; each one fetches
+// a page and up to a dozen feeds.
+const maxDiscoveries = 2
+
 // API handles HTTP requests and routes.
 type API struct {
-	r  *mux.Router
-	db storage.Storage
+	r           *mux.Router
+	db          storage.Storage
+	discoverer  Discoverer
+	discoveries chan struct{}  // slots of the running discoveries
+	publicURL   *url.URL       // base of the links of the published feeds
+	proxies     []netip.Prefix // peers whose X-Forwarded-* headers are believed
 }
 
 // New creates and initializes a new API instance.
@@ -27,6 +47,24 @@ func New(db storage.Storage) *API {
 	return &api
 }
 
+// SetDiscoverer enables feed discovery at POST /feeds/discover.
+func (api *API) SetDiscoverer(d Discoverer) {
+	api.discoverer = d
+	api.discoveries = make(chan struct{}, maxDiscoveries)
+}
+
+// SetPublicURL sets the address readers reach the service at. The links of the published feeds
+// are built from it instead of the request, whose headers the client controls.
+func (api *API) SetPublicURL(u *url.URL) {
+	api.publicURL = u
+}
+
+// SetTrustedProxies sets the peers, such as the gateway, whose X-Forwarded-Host and
+// X-Forwarded-Proto headers give the address readers used when no public URL is set.
+func (api *API) SetTrustedProxies(p []netip.Prefix) {
+	api.proxies = p
+}
+
 // Router returns the request router.
 func (api *API) Router() *mux.Router {
 	return api.r
@@ -38,13 +76,33 @@ func (api *API) endpoints() {
 	api.r.Use(api.loggingMiddleware)
 	api.r.HandleFunc("/news/filter", api.filterHandler).Methods(http.MethodGet)
 	api.r.HandleFunc("/news/new/{id}", api.postHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/news/cluster/{id}", api.clusterHandler).Methods(http.MethodGet)
 	api.r.HandleFunc("/news/{n}", api.postsHandler).Methods(http.MethodGet)
 	api.r.HandleFunc("/news", api.postsHandler).Methods(http.MethodGet)
 	api.r.HandleFunc("/news", api.addPostHandler).Methods(http.MethodPost)
+	api.r.HandleFunc("/news.rss", api.newsRSSHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/news.atom", api.newsAtomHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/tags", api.tagsHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/feeds", api.feedsHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/feeds", api.addFeedHandler).Methods(http.MethodPost)
+	api.r.HandleFunc("/feeds/discover", api.discoverFeedsHandler).Methods(http.MethodPost)
+	api.r.HandleFunc("/feeds.opml", api.exportFeedsHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/feeds.opml", api.importFeedsHandler).Methods(http.MethodPost)
+	api.r.HandleFunc("/feeds/{id}", api.feedHandler).Methods(http.MethodGet)
+	api.r.HandleFunc("/feeds/{id}", api.updateFeedHandler).Methods(http.MethodPatch)
+	api.r.HandleFunc("/feeds/{id}", api.deleteFeedHandler).Methods(http.MethodDelete)
 	api.r.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("./webapp"))))
 }
 
-// filterHandler - returns posts filtered by title substring.
+// defaultPerPage is the number of posts on a page of the news list and of search results
+// unless the client asks for another size.
+const defaultPerPage = 15
+
+// maxPerPage bounds the per_page and limit parameters.
+const maxPerPage = 100
+
+// filterHandler - returns a page of posts matching the full-text query, best matches first.
+// It accepts the same filters as the news list.
 func (api *API) filterHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
@@ -56,19 +114,40 @@ func (api *API) filterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	posts, err := api.db.SearchPosts(search)
+	query, err := queryParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Search = search
+
+	format, err := formatParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, pagination, err := api.db.QueryPosts(query)
 	if err != nil {
 		slog.Error("filterHandler: failed to search posts", "err", err, "request_id", requestID)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if len(posts) == 0 {
+	if len(results) == 0 {
 		http.Error(w, "no posts found", http.StatusNotFound)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(toDTOs(posts))
+	resp := struct {
+		News       []searchDTO
+		Pagination storage.Pagination
+	}{
+		News:       toSearchDTOs(results, format),
+		Pagination: pagination,
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		slog.Error("filterHandler: failed to encode JSON", "err", err, "request_id", requestID)
 		http.Error(w, "failed to encode response", http.StatusBadRequest)
@@ -89,6 +168,12 @@ func (api *API) postHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format, err := formatParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	post, err := api.db.Post(id)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -101,7 +186,7 @@ func (api *API) postHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(toDTO(post))
+	err = json.NewEncoder(w).Encode(toDTO(post, format))
 	if err != nil {
 		slog.Error("postHandler: failed to encode JSON", "err", err, "request_id", requestID)
 		http.Error(w, "failed to encode response", http.StatusBadRequest)
@@ -109,12 +194,85 @@ func (api *API) postHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// clusterHandler - returns the posts of a story cluster, oldest first.
+func (api *API) clusterHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	format, err := formatParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := api.db.ClusterPosts(id)
+	if err != nil {
+		slog.Error("clusterHandler: failed to get cluster posts", "id", id, "err", err, "request_id", requestID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(posts) == 0 {
+		http.Error(w, "cluster not found", http.StatusNotFound)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toDTOs(posts, format))
+	if err != nil {
+		slog.Error("clusterHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to encode response", http.StatusBadRequest)
+		return
+	}
+}
+
+// tagsHandler - returns the tags in use with their post counts, most used first.
+func (api *API) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	tags, err := api.db.Tags()
+	if err != nil {
+		slog.Error("tagsHandler: failed to get tags", "err", err, "request_id", requestID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toTagDTOs(tags))
+	if err != nil {
+		slog.Error("tagsHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to encode response", http.StatusBadRequest)
+		return
+	}
+}
+
 // postsHandler returns n posts and a paginated list of news posts.
+// Both can be narrowed by source, tag, from/to dates and a full-text query q, and sorted by sort;
+// format selects plain text or sanitized HTML content.
 func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 	requestID := getRequestID(r.Context())
 
+	query, err := queryParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := formatParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	vars := mux.Vars(r)
 	if rawN, ok := vars["n"]; ok {
 		n, err := strconv.Atoi(rawN)
@@ -123,14 +281,15 @@ func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		posts, err := api.db.Posts(n)
+		query.Page, query.PerPage = 1, n
+		results, _, err := api.db.QueryPosts(query)
 		if err != nil {
 			slog.Error("postsHandler: failed to get posts", "err", err, "request_id", requestID)
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		err = json.NewEncoder(w).Encode(toDTOs(posts))
+		err = json.NewEncoder(w).Encode(toListDTOs(results, query.Search != "", format))
 		if err != nil {
 			slog.Error("postsHandler: failed to encode JSON", "err", err, "request_id", requestID)
 			http.Error(w, "failed to encode response", http.StatusBadRequest)
@@ -139,29 +298,23 @@ func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pageStr := r.URL.Query().Get("page")
-	page := 1
-	if pageStr != "" {
-		p, err := strconv.Atoi(pageStr)
-		if err != nil || p <= 0 {
-			http.Error(w, "invalid page format", http.StatusBadRequest)
-			return
-		}
-		page = p
+	q := r.URL.Query()
+	if q.Has("cursor") || q.Has("limit") {
+		api.postsByCursor(w, r, query, format)
+		return
 	}
 
-	const perPage = 15
-	posts, pagination, err := api.db.GetPostsPaginated(page, perPage)
+	results, pagination, err := api.db.QueryPosts(query)
 	if err != nil {
 		slog.Error("postsHandler: failed to fetch posts", "err", err, "request_id", requestID)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 	resp := struct {
-		News       []postDTO
+		News       any
 		Pagination storage.Pagination
 	}{
-		News:       toDTOs(posts),
+		News:       toListDTOs(results, query.Search != "", format),
 		Pagination: pagination,
 	}
 
@@ -173,6 +326,168 @@ func (api *API) postsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// postsByCursor returns a page of the filtered news list next to the opaque cursor together
+// with the cursors of the neighbouring pages. An empty cursor starts from the newest post.
+// Keyset pages always run newest first, so other sort orders are rejected.
+func (api *API) postsByCursor(w http.ResponseWriter, r *http.Request, query storage.PostQuery, format contentFormat) {
+	requestID := getRequestID(r.Context())
+
+	if query.Sort != "" && query.Sort != storage.SortNewest {
+		http.Error(w, "sort is not supported with cursor", http.StatusBadRequest)
+		return
+	}
+
+	cursor, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		http.Error(w, "invalid cursor format", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := sizeParam(r, "limit")
+	if err != nil {
+		http.Error(w, "invalid limit format", http.StatusBadRequest)
+		return
+	}
+
+	posts, page, err := api.db.PostsByCursor(cursor, limit, query.PostFilter)
+	if err != nil {
+		slog.Error("postsHandler: failed to fetch posts by cursor", "err", err, "request_id", requestID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := struct {
+		News       []postDTO
+		NextCursor string
+		PrevCursor string
+	}{
+		News:       toDTOs(posts, format),
+		NextCursor: encodeCursor(page.Next),
+		PrevCursor: encodeCursor(page.Prev),
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		slog.Error("postsHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to encode response", http.StatusBadRequest)
+		return
+	}
+}
+
+// queryParams reads the news list query from the request: the source, from, to, q, tag, duplicates
+// and sort filters and the page and per_page parameters. The errors are meant for the client.
+func queryParams(r *http.Request) (storage.PostQuery, error) {
+	var query storage.PostQuery
+	q := r.URL.Query()
+
+	if raw := q.Get("source"); raw != "" {
+		source, err := strconv.Atoi(raw)
+		if err != nil || source <= 0 {
+			return query, errors.New("invalid source format")
+		}
+		query.Source = source
+	}
+
+	if raw := q.Get("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, errors.New("invalid from format, want RFC 3339")
+		}
+		query.From = from
+	}
+
+	if raw := q.Get("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, errors.New("invalid to format, want RFC 3339")
+		}
+		query.To = to
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && query.From.After(query.To) {
+		return query, errors.New("from is after to")
+	}
+
+	query.Search = strings.TrimSpace(q.Get("q"))
+
+	if raw := q.Get("tag"); raw != "" {
+		query.Tag = storage.NormalizeTag(raw)
+		if query.Tag == "" {
+			return query, errors.New("invalid tag format")
+		}
+	}
+
+	if raw := q.Get("duplicates"); raw != "" {
+		duplicates, err := strconv.ParseBool(raw)
+		if err != nil {
+			return query, errors.New("invalid duplicates format")
+		}
+		query.Duplicates = duplicates
+	}
+
+	switch sort := storage.Sort(q.Get("sort")); sort {
+	case "", storage.SortNewest, storage.SortOldest, storage.SortRelevance:
+		query.Sort = sort
+	default:
+		return query, errors.New("invalid sort format")
+	}
+
+	page, err := pageParam(r)
+	if err != nil {
+		return query, errors.New("invalid page format")
+	}
+	query.Page = page
+
+	perPage, err := sizeParam(r, "per_page")
+	if err != nil {
+		return query, errors.New("invalid per_page format")
+	}
+	query.PerPage = perPage
+
+	return query, nil
+}
+
+// formatParam returns the content format from the query, plain text if it is absent.
+func formatParam(r *http.Request) (contentFormat, error) {
+	switch f := contentFormat(r.URL.Query().Get("format")); f {
+	case "":
+		return formatText, nil
+	case formatText, formatHTML:
+		return f, nil
+	default:
+		return "", errors.New("invalid format, want html or text")
+	}
+}
+
+// pageParam returns the positive page number from the query, 1 if it is absent.
+func pageParam(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("page")
+	if raw == "" {
+		return 1, nil
+	}
+
+	page, err := strconv.Atoi(raw)
+	if err != nil || page <= 0 {
+		return 0, errors.New("invalid page")
+	}
+	return page, nil
+}
+
+// sizeParam returns the page size from the named query parameter, defaultPerPage if it is absent.
+// Sizes outside 1..maxPerPage are rejected.
+func sizeParam(r *http.Request, name string) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultPerPage, nil
+	}
+
+	size, err := strconv.Atoi(raw)
+	if err != nil || size <= 0 || size > maxPerPage {
+		return 0, errors.New("invalid page size")
+	}
+	return size, nil
+}
+
 // addPostHandler - creates a new post.
 func (api *API) addPostHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := getRequestID(r.Context())
@@ -185,6 +500,25 @@ func (api *API) addPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, m := range p.Media {
+		if !validMedia(m) {
+			slog.Warn("addPostHandler: invalid media", "url", m.URL, "request_id", requestID)
+			http.Error(w, "media needs an absolute http(s) URL and non-negative length and size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Only markup sanitized during ingestion is served as HTML.
+	p.ContentHTML = ""
+
+	var tags []string
+	for _, t := range p.Tags {
+		if tag := storage.NormalizeTag(t); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	p.Tags = tags
+
 	post, err := api.db.AddPost(p)
 	if err != nil {
 		slog.Error("addPostHandler: failed to add post", "err", err, "request_id", requestID)
@@ -199,3 +533,13 @@ func (api *API) addPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// validMedia reports whether a media item sent with a post has an absolute http or https URL
+// and no negative length or size.
+func validMedia(m storage.Media) bool {
+	u, err := url.Parse(strings.TrimSpace(m.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	return m.Length >= 0 && m.Width >= 0 && m.Height >= 0
+}
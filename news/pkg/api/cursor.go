@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"news/pkg/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errInvalidCursor is returned for cursors that were not produced by encodeCursor.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor returns the opaque form of the cursor handed out to clients.
+// A nil cursor encodes to an empty string.
+func encodeCursor(c *storage.Cursor) string {
+	if c == nil {
+		return ""
+	}
+
+	dir := "n"
+	if c.Before {
+		dir = "p"
+	}
+	raw := fmt.Sprintf("%s:%d:%d", dir, c.PubTime.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor. An empty string yields nil,
+// which stands for the first page.
+func decodeCursor(s string) (*storage.Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "p") {
+		return nil, errInvalidCursor
+	}
+
+	micro, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	id, err := strconv.Atoi(parts[2])
+	if err != nil || id <= 0 {
+		return nil, errInvalidCursor
+	}
+
+	return &storage.Cursor{
+		PubTime: time.UnixMicro(micro).UTC(),
+		ID:      id,
+		Before:  parts[0] == "p",
+	}, nil
+}
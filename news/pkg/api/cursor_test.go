@@ -0,0 +1,47 @@
+package api
+
+import (
+	"news/pkg/storage"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_cursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *storage.Cursor
+	}{
+		{
+			name: "Next",
+			c:    &storage.Cursor{PubTime: time.Date(2024, 3, 1, 10, 30, 0, 123456000, time.UTC), ID: 42},
+		},
+		{
+			name: "Prev",
+			c:    &storage.Cursor{PubTime: time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC), ID: 7, Before: true},
+		},
+		{
+			name: "First page",
+			c:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(encodeCursor(tt.c))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.c) {
+				t.Errorf("decodeCursor() = %+v, want %+v", got, tt.c)
+			}
+		})
+	}
+}
+
+func Test_decodeCursorInvalid(t *testing.T) {
+	for _, s := range []string{"!!!", "bjoxOjI6Mw", "eDoxOjI", "bjp4OjI", "bjoxOjA"} {
+		if _, err := decodeCursor(s); err == nil {
+			t.Errorf("decodeCursor(%q) expected error", s)
+		}
+	}
+}
@@ -1,14 +1,46 @@
 package api
 
-import "news/pkg/storage"
+import (
+	"html"
+	"news/pkg/discover"
+	"news/pkg/opml"
+	"news/pkg/storage"
+	"time"
+)
 
 // postDTO represents a data transfer object for a single news post.
+// FullContent is the article body extracted from Link for full-text feeds.
+// ClusterID identifies the story; Duplicates counts the other posts of the story in lists.
 type postDTO struct {
-	ID      int
-	Title   string
-	Content string
-	PubTime int64
-	Link    string
+	ID            int
+	Title         string
+	Author        string
+	Content       string
+	FullContent   string
+	PubTime       int64
+	DateEstimated bool
+	Link          string
+	Source        sourceDTO
+	ClusterID     int
+	Duplicates    int
+	Media         []mediaDTO
+	Tags          []string
+}
+
+// mediaDTO represents an image, audio or video attached to a post; zero sizes are unknown.
+type mediaDTO struct {
+	URL    string
+	Type   string
+	Length int64
+	Width  int
+	Height int
+}
+
+// sourceDTO represents the feed a post came from; ID is the feed ID.
+type sourceDTO struct {
+	ID    int
+	Title string
+	Link  string
 }
 
 // NewsListResponse represents a response structure containing a list of posts and pagination info.
@@ -17,22 +49,218 @@ type NewsListResponse struct {
 	Pagination storage.Pagination
 }
 
-// toDTO converts a storage.Post entity to a postDTO.
-func toDTO(p storage.Post) postDTO {
+// contentFormat selects how post content is returned: plain text or sanitized HTML.
+type contentFormat string
+
+const (
+	formatText contentFormat = "text"
+	formatHTML contentFormat = "html"
+)
+
+// content returns the post content in the format. Posts without sanitized HTML
+// are returned as escaped plain text in the HTML format.
+func (f contentFormat) content(p storage.Post) string {
+	if f != formatHTML {
+		return p.Content
+	}
+	if p.ContentHTML != "" {
+		return p.ContentHTML
+	}
+	return html.EscapeString(p.Content)
+}
+
+// toDTO converts a storage.Post entity to a postDTO with content in the format.
+func toDTO(p storage.Post, f contentFormat) postDTO {
 	return postDTO{
-		ID:      p.ID,
-		Title:   p.Title,
-		Content: p.Content,
-		PubTime: p.PubTime.Unix(),
-		Link:    p.Link,
+		ID:            p.ID,
+		Title:         p.Title,
+		Author:        p.Author,
+		Content:       f.content(p),
+		FullContent:   p.FullContent,
+		PubTime:       p.PubTime.Unix(),
+		DateEstimated: p.DateEstimated,
+		Link:          p.Link,
+		Source: sourceDTO{
+			ID:    p.Source.FeedID,
+			Title: p.Source.Title,
+			Link:  p.Source.Link,
+		},
+		ClusterID: p.ClusterID,
+		Media:     toMediaDTOs(p.Media),
+		Tags:      append([]string{}, p.Tags...),
+	}
+}
+
+// toMediaDTOs converts the media of a post; a post without media gets an empty list.
+func toMediaDTOs(media []storage.Media) []mediaDTO {
+	out := make([]mediaDTO, len(media))
+	for i, m := range media {
+		out[i] = mediaDTO(m)
 	}
+	return out
 }
 
 // toDTOs converts a slice of storage.Post entities to a slice of postDTOs.
-func toDTOs(p []storage.Post) []postDTO {
+func toDTOs(p []storage.Post, f contentFormat) []postDTO {
 	out := make([]postDTO, len(p))
 	for i := range p {
-		out[i] = toDTO(p[i])
+		out[i] = toDTO(p[i], f)
+	}
+	return out
+}
+
+// searchDTO represents a post found by full-text search with its rank and highlighted snippet.
+type searchDTO struct {
+	postDTO
+	Rank     float64
+	Headline string
+}
+
+// toSearchDTOs converts search results to a slice of searchDTOs.
+func toSearchDTOs(r []storage.SearchResult, f contentFormat) []searchDTO {
+	out := make([]searchDTO, len(r))
+	for i := range r {
+		out[i] = searchDTO{
+			postDTO:  toDTO(r[i].Post, f),
+			Rank:     r[i].Rank,
+			Headline: r[i].Headline,
+		}
+		out[i].Duplicates = r[i].Duplicates
+	}
+	return out
+}
+
+// toListDTOs converts a page of the news list. Full-text results keep their rank and snippet,
+// other lists are plain posts.
+func toListDTOs(r []storage.SearchResult, search bool, f contentFormat) any {
+	if search {
+		return toSearchDTOs(r, f)
+	}
+
+	out := make([]postDTO, len(r))
+	for i := range r {
+		out[i] = toDTO(r[i].Post, f)
+		out[i].Duplicates = r[i].Duplicates
+	}
+	return out
+}
+
+// feedDTO represents a data transfer object for a feed, its settings and its health.
+// Times are Unix seconds; 0 means the event has not happened yet.
+type feedDTO struct {
+	ID            int
+	URL           string
+	Title         string
+	Category      string
+	Interval      int
+	Enabled       bool
+	FullText      bool
+	MaxBodySize   int64
+	LastAttempt   int64
+	LastSuccess   int64
+	LastError     string
+	Failures      int
+	ItemsIngested int
+}
+
+// feedRequest represents the body of feed create and update requests.
+// Fields left out of an update keep their current values.
+type feedRequest struct {
+	URL         *string
+	Title       *string
+	Category    *string
+	Interval    *int
+	Enabled     *bool
+	FullText    *bool
+	MaxBodySize *int64
+}
+
+// discoverRequest represents the body of feed discovery requests: the address of a page of the site.
+type discoverRequest struct {
+	URL string
+}
+
+// discoveredFeedDTO represents a feed found for a page, with its title and number of items.
+type discoveredFeedDTO struct {
+	URL   string
+	Title string
+	Items int
+}
+
+// toDiscoveredFeedDTOs converts discovered feeds; no feeds give an empty list.
+func toDiscoveredFeedDTOs(feeds []discover.Feed) []discoveredFeedDTO {
+	out := make([]discoveredFeedDTO, len(feeds))
+	for i, f := range feeds {
+		out[i] = discoveredFeedDTO(f)
+	}
+	return out
+}
+
+// toFeedDTO converts a storage.Feed entity to a feedDTO.
+func toFeedDTO(f storage.Feed) feedDTO {
+	return feedDTO{
+		ID:            f.ID,
+		URL:           f.URL,
+		Title:         f.Title,
+		Category:      f.Category,
+		Interval:      f.Interval,
+		Enabled:       f.Enabled,
+		FullText:      f.FullText,
+		MaxBodySize:   f.MaxBodySize,
+		LastAttempt:   unix(f.LastAttempt),
+		LastSuccess:   unix(f.LastSuccess),
+		LastError:     f.LastError,
+		Failures:      f.Failures,
+		ItemsIngested: f.ItemsIngested,
+	}
+}
+
+// toFeedDTOs converts a slice of storage.Feed entities to a slice of feedDTOs.
+func toFeedDTOs(f []storage.Feed) []feedDTO {
+	out := make([]feedDTO, len(f))
+	for i := range f {
+		out[i] = toFeedDTO(f[i])
+	}
+	return out
+}
+
+// toOPMLFeeds converts feeds to the outlines of an OPML list.
+func toOPMLFeeds(f []storage.Feed) []opml.Feed {
+	out := make([]opml.Feed, len(f))
+	for i := range f {
+		out[i] = opml.Feed{URL: f[i].URL, Title: f[i].Title, Category: f[i].Category}
+	}
+	return out
+}
+
+// fromOPMLFeeds converts the outlines of an imported OPML list to enabled feeds.
+func fromOPMLFeeds(f []opml.Feed) []storage.Feed {
+	out := make([]storage.Feed, len(f))
+	for i := range f {
+		out[i] = storage.Feed{URL: f[i].URL, Title: f[i].Title, Category: f[i].Category, Enabled: true}
+	}
+	return out
+}
+
+// unix returns t as Unix seconds, or 0 for the zero time.
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// tagDTO represents a tag and the number of posts carrying it.
+type tagDTO struct {
+	Name  string
+	Posts int
+}
+
+// toTagDTOs converts tags to a slice of tagDTOs.
+func toTagDTOs(tags []storage.Tag) []tagDTO {
+	out := make([]tagDTO, len(tags))
+	for i, t := range tags {
+		out[i] = tagDTO(t)
 	}
 	return out
 }
@@ -23,9 +23,12 @@ func Test_toDTOs(t *testing.T) {
 					{
 						ID:      1,
 						Title:   "Title 1",
+						Author:  "Jane Doe",
 						Content: "Content 1",
 						PubTime: time.Date(2006, 9, 11, 12, 14, 0, 0, time.UTC),
 						Link:    "https://example.ru/ru/articles/108175/=rss",
+						Media:   []storage.Media{{URL: "https://example.ru/1.jpg", Type: "image/jpeg", Width: 640, Height: 480}},
+						Tags:    []string{"go", "releases"},
 					},
 					{
 						ID:      2,
@@ -40,9 +43,12 @@ func Test_toDTOs(t *testing.T) {
 				{
 					ID:      1,
 					Title:   "Title 1",
+					Author:  "Jane Doe",
 					Content: "Content 1",
 					PubTime: 1157976840,
 					Link:    "https://example.ru/ru/articles/108175/=rss",
+					Media:   []mediaDTO{{URL: "https://example.ru/1.jpg", Type: "image/jpeg", Width: 640, Height: 480}},
+					Tags:    []string{"go", "releases"},
 				},
 				{
 					ID:      2,
@@ -50,15 +56,96 @@ func Test_toDTOs(t *testing.T) {
 					Content: "Content 2",
 					PubTime: 1129122900,
 					Link:    "https://example.ru/ru/articles/981612/=rss",
+					Media:   []mediaDTO{},
+					Tags:    []string{},
 				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := toDTOs(tt.args.p); !reflect.DeepEqual(got, tt.want) {
+			if got := toDTOs(tt.args.p, formatText); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("toDTOs() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_toSearchDTOs(t *testing.T) {
+	results := []storage.SearchResult{
+		{
+			Post: storage.Post{
+				ID:      1,
+				Title:   "Title 1",
+				Content: "Content 1",
+				PubTime: time.Date(2006, 9, 11, 12, 14, 0, 0, time.UTC),
+				Link:    "https://example.ru/ru/articles/108175/=rss",
+			},
+			Rank:     0.6,
+			Headline: "<b>Content</b> 1",
+		},
+	}
+	want := []searchDTO{
+		{
+			postDTO: postDTO{
+				ID:      1,
+				Title:   "Title 1",
+				Content: "Content 1",
+				PubTime: 1157976840,
+				Link:    "https://example.ru/ru/articles/108175/=rss",
+				Media:   []mediaDTO{},
+				Tags:    []string{},
+			},
+			Rank:     0.6,
+			Headline: "<b>Content</b> 1",
+		},
+	}
+
+	if got := toSearchDTOs(results, formatText); !reflect.DeepEqual(got, want) {
+		t.Errorf("toSearchDTOs() = %v, want %v", got, want)
+	}
+}
+
+func Test_toListDTOs(t *testing.T) {
+	results := []storage.SearchResult{
+		{
+			Post:       storage.Post{ID: 1, Title: "Title 1", PubTime: time.Unix(1157976840, 0)},
+			Rank:       0.6,
+			Headline:   "<b>Title</b>",
+			Duplicates: 2,
+		},
+	}
+
+	plain, ok := toListDTOs(results, false, formatText).([]postDTO)
+	if !ok || len(plain) != 1 || plain[0].ID != 1 || plain[0].PubTime != 1157976840 || plain[0].Duplicates != 2 {
+		t.Errorf("toListDTOs(plain) = %+v", plain)
+	}
+
+	search, ok := toListDTOs(results, true, formatText).([]searchDTO)
+	if !ok || len(search) != 1 || search[0].Rank != 0.6 || search[0].Headline != "<b>Title</b>" || search[0].Duplicates != 2 {
+		t.Errorf("toListDTOs(search) = %+v", search)
+	}
+}
+
+func Test_contentFormat(t *testing.T) {
+	rich := storage.Post{Content: "Bold & text", ContentHTML: "<p><b>Bold</b> &amp; text</p>"}
+	plain := storage.Post{Content: "a < b"}
+
+	tests := []struct {
+		name   string
+		format contentFormat
+		post   storage.Post
+		want   string
+	}{
+		{name: "Text", format: formatText, post: rich, want: "Bold & text"},
+		{name: "HTML", format: formatHTML, post: rich, want: "<p><b>Bold</b> &amp; text</p>"},
+		{name: "HTML without markup", format: formatHTML, post: plain, want: "a &lt; b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toDTO(tt.post, tt.format).Content; got != tt.want {
+				t.Errorf("toDTO().Content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"news/pkg/netguard"
+	"news/pkg/opml"
+	"news/pkg/storage"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// feedsHandler - returns all feeds with their health.
+func (api *API) feedsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	feeds, err := api.db.Feeds()
+	if err != nil {
+		slog.Error("feedsHandler: failed to get feeds", "err", err, "request_id", requestID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toFeedDTOs(feeds))
+	if err != nil {
+		slog.Error("feedsHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to encode response", http.StatusBadRequest)
+		return
+	}
+}
+
+// feedHandler - returns the feed health by id.
+func (api *API) feedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	rawID := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	feed, err := api.db.Feed(id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			slog.Warn("feedHandler: feed not found", "id", id, "err", err, "request_id", requestID)
+			http.Error(w, "feed not found", http.StatusNotFound)
+		} else {
+			slog.Error("feedHandler: failed to get feed", "id", id, "err", err, "request_id", requestID)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toFeedDTO(feed))
+	if err != nil {
+		slog.Error("feedHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to encode response", http.StatusBadRequest)
+		return
+	}
+}
+
+// addFeedHandler - creates a new feed; the aggregator picks it up on its next tick.
+func (api *API) addFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	var req feedRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		slog.Error("addFeedHandler: failed to decode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == nil || !validFeedURL(*req.URL) {
+		http.Error(w, "invalid feed url", http.StatusBadRequest)
+		return
+	}
+
+	f := storage.Feed{URL: strings.TrimSpace(*req.URL), Enabled: true}
+	if !applyFeedRequest(&f, req) {
+		http.Error(w, "invalid interval or max body size", http.StatusBadRequest)
+		return
+	}
+
+	feed, err := api.db.AddFeed(f)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "feed already exists", http.StatusConflict)
+			return
+		}
+		slog.Error("addFeedHandler: failed to add feed", "err", err, "request_id", requestID)
+		http.Error(w, "failed to create feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(toFeedDTO(feed))
+	if err != nil {
+		slog.Error("addFeedHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		return
+	}
+}
+
+// discoverFeedsHandler - finds the feeds of a site from the address of one of its pages,
+// checks them with the feed parser and returns their titles and item counts.
+// Pages on non-public addresses are refused, and requests beyond maxDiscoveries running
+// at once get 429 Too Many Requests.
+func (api *API) discoverFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	if api.discoverer == nil {
+		http.Error(w, "feed discovery is disabled", http.StatusNotImplemented)
+		return
+	}
+
+	var req discoverRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		slog.Error("discoverFeedsHandler: failed to decode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	if !validFeedURL(req.URL) {
+		http.Error(w, "invalid page url", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case api.discoveries <- struct{}{}:
+		defer func() { <-api.discoveries }()
+	default:
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "too many feed discoveries, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	feeds, err := api.discoverer.Discover(r.Context(), strings.TrimSpace(req.URL))
+	if errors.Is(err, netguard.ErrPrivateAddress) {
+		http.Error(w, "page address is not public", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Warn("discoverFeedsHandler: failed to discover feeds", "url", req.URL, "err", err, "request_id", requestID)
+		http.Error(w, "failed to fetch page", http.StatusBadGateway)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toDiscoveredFeedDTOs(feeds))
+	if err != nil {
+		slog.Error("discoverFeedsHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		return
+	}
+}
+
+// maxOPMLSize bounds the size of uploaded OPML lists.
+const maxOPMLSize = 5 << 20
+
+// exportFeedsHandler - returns the feed list as an OPML document with a folder for every category.
+func (api *API) exportFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	feeds, err := api.db.Feeds()
+	if err != nil {
+		slog.Error("exportFeedsHandler: failed to get feeds", "err", err, "request_id", requestID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="feeds.opml"`)
+	err = opml.Write(w, "News feeds", toOPMLFeeds(feeds))
+	if err != nil {
+		slog.Error("exportFeedsHandler: failed to write OPML", "err", err, "request_id", requestID)
+		return
+	}
+}
+
+// importFeedsHandler - merges an uploaded OPML list into the feeds: new URLs are added, known
+// feeds only get the title and category they lack. The list is either the request body or
+// the "file" field of a multipart form.
+func (api *API) importFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxOPMLSize)
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			slog.Warn("importFeedsHandler: failed to read upload", "err", err, "request_id", requestID)
+			http.Error(w, "missing OPML file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	list, err := opml.Parse(body)
+	if err != nil {
+		slog.Warn("importFeedsHandler: failed to parse OPML", "err", err, "request_id", requestID)
+		http.Error(w, "invalid OPML document", http.StatusBadRequest)
+		return
+	}
+
+	res, err := api.db.ImportFeeds(fromOPMLFeeds(list))
+	if err != nil {
+		slog.Error("importFeedsHandler: failed to import feeds", "err", err, "request_id", requestID)
+		http.Error(w, "failed to import feeds", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("feeds imported", "inserted", res.Inserted, "updated", res.Updated, "unchanged", res.Unchanged, "request_id", requestID)
+
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		slog.Error("importFeedsHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		return
+	}
+}
+
+// updateFeedHandler - renames, files, enables or disables a feed, changes its interval or toggles full text extraction.
+func (api *API) updateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	requestID := getRequestID(r.Context())
+
+	rawID := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	var req feedRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		slog.Error("updateFeedHandler: failed to decode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	if req.URL != nil {
+		http.Error(w, "feed url cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	f, err := api.db.Feed(id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "feed not found", http.StatusNotFound)
+		} else {
+			slog.Error("updateFeedHandler: failed to get feed", "id", id, "err", err, "request_id", requestID)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !applyFeedRequest(&f, req) {
+		http.Error(w, "invalid interval or max body size", http.StatusBadRequest)
+		return
+	}
+
+	feed, err := api.db.UpdateFeed(f)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "feed not found", http.StatusNotFound)
+		} else {
+			slog.Error("updateFeedHandler: failed to update feed", "id", id, "err", err, "request_id", requestID)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(toFeedDTO(feed))
+	if err != nil {
+		slog.Error("updateFeedHandler: failed to encode JSON", "err", err, "request_id", requestID)
+		http.Error(w, "failed to encode response", http.StatusBadRequest)
+		return
+	}
+}
+
+// deleteFeedHandler - removes a feed; posts already ingested from it are kept.
+func (api *API) deleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Context())
+
+	rawID := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id format", http.StatusBadRequest)
+		return
+	}
+
+	err = api.db.DeleteFeed(id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "feed not found", http.StatusNotFound)
+		} else {
+			slog.Error("deleteFeedHandler: failed to delete feed", "id", id, "err", err, "request_id", requestID)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyFeedRequest copies the present request fields onto the feed.
+// It reports false if the interval or the body size limit is negative.
+func applyFeedRequest(f *storage.Feed, req feedRequest) bool {
+	if req.Title != nil {
+		f.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Category != nil {
+		f.Category = strings.Trim(strings.TrimSpace(*req.Category), "/")
+	}
+	if req.Interval != nil {
+		if *req.Interval < 0 {
+			return false
+		}
+		f.Interval = *req.Interval
+	}
+	if req.Enabled != nil {
+		f.Enabled = *req.Enabled
+	}
+	if req.FullText != nil {
+		f.FullText = *req.FullText
+	}
+	if req.MaxBodySize != nil {
+		if *req.MaxBodySize < 0 {
+			return false
+		}
+		f.MaxBodySize = *req.MaxBodySize
+	}
+	return true
+}
+
+// validFeedURL reports whether raw is an absolute http(s) URL.
+func validFeedURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"news/pkg/storage"
+	"news/pkg/syndicate"
+	"strconv"
+)
+
+// feedSize is the number of posts in the published feeds unless the client asks for another size.
+const feedSize = 50
+
+// feedMaxAge is how long, in seconds, clients and proxies may reuse a published feed.
+const feedMaxAge = 300
+
+// newsRSSHandler - publishes the news list as an RSS 2.0 feed.
+func (api *API) newsRSSHandler(w http.ResponseWriter, r *http.Request) {
+	api.publish(w, r, "application/rss+xml; charset=utf-8", syndicate.RSS)
+}
+
+// newsAtomHandler - publishes the news list as an Atom 1.0 feed.
+func (api *API) newsAtomHandler(w http.ResponseWriter, r *http.Request) {
+	api.publish(w, r, "application/atom+xml; charset=utf-8", syndicate.Atom)
+}
+
+// publish writes the newest posts of the news list as a feed. It accepts the filters of the list
+// (source, tag, from/to, q, duplicates) and per_page, but the feed always runs newest first.
+// Responses carry an ETag of the document and the time of its newest post, so readers polling
+// with conditional requests get 304 Not Modified until new posts arrive.
+func (api *API) publish(w http.ResponseWriter, r *http.Request, contentType string, write func(io.Writer, syndicate.Channel, []storage.Post) error) {
+	requestID := getRequestID(r.Context())
+
+	query, err := queryParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Sort = storage.SortNewest
+	if !r.URL.Query().Has("per_page") {
+		query.PerPage = feedSize
+	}
+
+	results, _, err := api.db.QueryPosts(query)
+	if err != nil {
+		slog.Error("publish: failed to fetch posts", "err", err, "request_id", requestID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	posts := make([]storage.Post, len(results))
+	for i := range results {
+		posts[i] = results[i].Post
+	}
+
+	var buf bytes.Buffer
+	err = write(&buf, api.channel(r), posts)
+	if err != nil {
+		slog.Error("publish: failed to write feed", "err", err, "request_id", requestID)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(feedMaxAge))
+	http.ServeContent(w, r, "", syndicate.Updated(posts), bytes.NewReader(buf.Bytes()))
+}
+
+// channel describes the published feed. The feeds are cached publicly, so their addresses come
+// from the public URL of the service if one is set. Otherwise they are those of the request,
+// with the X-Forwarded-Proto and X-Forwarded-Host headers believed only from trusted proxies.
+// The request_id parameter is left out of them.
+func (api *API) channel(r *http.Request) syndicate.Channel {
+	var base url.URL
+	if api.publicURL != nil {
+		base = url.URL{Scheme: api.publicURL.Scheme, Host: api.publicURL.Host}
+	} else {
+		base = url.URL{Scheme: "http", Host: r.Host}
+		if r.TLS != nil {
+			base.Scheme = "https"
+		}
+		if api.trustedProxy(r) {
+			if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+				base.Scheme = proto
+			}
+			if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+				base.Host = host
+			}
+		}
+	}
+
+	q := r.URL.Query()
+	q.Del("request_id")
+	self := base
+	self.Path = r.URL.Path
+	self.RawQuery = q.Encode()
+	link := base
+	link.Path = "/"
+
+	return syndicate.Channel{
+		Title:       "News",
+		Description: "News aggregated from all feeds",
+		Link:        link.String(),
+		Self:        self.String(),
+	}
+}
+
+// trustedProxy reports whether the request comes straight from one of the trusted proxies.
+func (api *API) trustedProxy(r *http.Request) bool {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := peer.Addr().Unmap()
+	for _, p := range api.proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
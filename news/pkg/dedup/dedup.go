@@ -0,0 +1,109 @@
+// Package dedup recognises the same story published under different links:
+// it normalizes article URLs and fingerprints article text with SimHash.
+package dedup
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// MaxDistance is the largest Hamming distance between the fingerprints of two texts
+// that are still considered the same story. News teasers are short, so a few changed
+// words move several bits; unrelated texts differ in about half of the 64 bits.
+const MaxDistance = 7
+
+// minWords is the shortest text, in words, that gets a fingerprint; shorter texts
+// share too few features for their fingerprints to tell stories apart.
+const minWords = 5
+
+// NormalizeURL returns the canonical form of an article link: lower-case scheme and host,
+// no default port, no fragment and no utm_* tracking parameters. The remaining query
+// is kept as is. Links that cannot be parsed are only trimmed.
+func NormalizeURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "http" && u.Port() == "80":
+		u.Host = strings.TrimSuffix(u.Host, ":80")
+	case u.Scheme == "https" && u.Port() == "443":
+		u.Host = strings.TrimSuffix(u.Host, ":443")
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.RawQuery = stripTracking(u.RawQuery)
+	u.ForceQuery = false
+
+	return u.String()
+}
+
+// stripTracking drops the utm_* parameters from a raw query, keeping the order and
+// encoding of the others.
+func stripTracking(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, p := range params {
+		key, _, _ := strings.Cut(p, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if p == "" || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return strings.Join(kept, "&")
+}
+
+// SimHash returns the 64-bit SimHash fingerprint of the text built from its words.
+// Texts that differ in a few words get fingerprints a few bits apart.
+// Texts shorter than a few words get 0, which stands for no fingerprint.
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < minWords {
+		return 0
+	}
+
+	var weights [64]int
+	for _, w := range words {
+		h := fnv.New64a()
+		h.Write([]byte(w))
+		sum := h.Sum64()
+
+		for b := range weights {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var fp uint64
+	for b, w := range weights {
+		if w > 0 {
+			fp |= 1 << b
+		}
+	}
+	return fp
+}
+
+// Distance returns the number of bits in which two fingerprints differ.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
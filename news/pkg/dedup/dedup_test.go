@@ -0,0 +1,72 @@
+package dedup
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "Tracking parameters and fragment",
+			raw:  "https://example.com/news/1?utm_source=rss&id=5&UTM_Medium=feed#comments",
+			want: "https://example.com/news/1?id=5",
+		},
+		{
+			name: "Only tracking parameters",
+			raw:  "https://example.com/news/1?utm_source=rss&utm_campaign=x",
+			want: "https://example.com/news/1",
+		},
+		{
+			name: "Scheme and host case, default port",
+			raw:  "HTTPS://Example.COM:443/News/Item",
+			want: "https://example.com/News/Item",
+		},
+		{
+			name: "Non-default port kept",
+			raw:  "http://example.com:8080/a",
+			want: "http://example.com:8080/a",
+		},
+		{
+			name: "Query order and encoding kept",
+			raw:  " https://example.com/search?q=a+b&lang=ru ",
+			want: "https://example.com/search?q=a+b&lang=ru",
+		},
+		{
+			name: "Relative link left alone",
+			raw:  "/news/1#top",
+			want: "/news/1#top",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeURL(tt.raw); got != tt.want {
+				t.Errorf("NormalizeURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	story := "Центральный банк сохранил ключевую ставку на уровне 16 процентов годовых, сообщила пресс-служба регулятора в пятницу"
+	edited := "Центральный банк сохранил ключевую ставку на уровне 16 процентов годовых, сообщила пресс-служба регулятора в пятницу вечером"
+	other := "Сборная России по хоккею обыграла команду Казахстана в товарищеском матче со счётом 4:1 и вышла в финал турнира"
+
+	a, b, c := SimHash(story), SimHash(edited), SimHash(other)
+	if a == 0 || b == 0 || c == 0 {
+		t.Fatalf("SimHash() returned no fingerprint: %x %x %x", a, b, c)
+	}
+	if d := Distance(a, b); d > MaxDistance {
+		t.Errorf("Distance(story, edited) = %d, want <= %d", d, MaxDistance)
+	}
+	if d := Distance(a, c); d <= MaxDistance {
+		t.Errorf("Distance(story, other) = %d, want > %d", d, MaxDistance)
+	}
+	if SimHash("Case  and PUNCTUATION, do not matter here!") != SimHash("case and punctuation do not matter here") {
+		t.Errorf("SimHash() depends on case or punctuation")
+	}
+	if got := SimHash("Too short"); got != 0 {
+		t.Errorf("SimHash(short) = %x, want 0", got)
+	}
+}
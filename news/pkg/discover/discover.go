@@ -0,0 +1,203 @@
+// Package discover finds the feeds of a website from the address of one of its pages,
+// for people who paste a homepage instead of a feed URL.
+package discover
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"news/pkg/netguard"
+	"news/pkg/rss"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxPageSize bounds how much of the HTML page is read.
+const maxPageSize = 2 << 20
+
+// maxCandidates bounds how many feed URLs are validated for a page.
+const maxCandidates = 12
+
+// commonPaths are probed on the site when looking for feeds the page does not advertise.
+var commonPaths = []string{"/feed", "/rss", "/feed.xml", "/rss.xml", "/atom.xml", "/index.xml"}
+
+// feedTypes are the link types that advertise a feed.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// Feed is a feed found for a page, checked by parsing it.
+type Feed struct {
+	URL   string
+	Title string // the channel title, or the link title if the channel has none
+	Items int
+}
+
+// Discoverer fetches pages and the feeds they lead to with its own timeout.
+// Since the pages come from users, it only connects to public addresses, checked on every
+// connection so that redirects and feed links cannot reach the internal network either;
+// such requests fail with netguard.ErrPrivateAddress. It is safe for concurrent use.
+type Discoverer struct {
+	client  *http.Client
+	maxBody int64
+}
+
+// New creates a Discoverer that waits at most timeout for each request and reads feeds
+// of at most maxBody bytes; a non-positive maxBody means rss.DefaultMaxBodySize.
+func New(timeout time.Duration, maxBody int64) *Discoverer {
+	return &Discoverer{client: netguard.Client(timeout), maxBody: maxBody}
+}
+
+// Discover returns the feeds of the page: the page itself if it is a feed, the feeds it
+// advertises with <link rel="alternate"> and those found at common paths of the site,
+// in that order. Only candidates that parse as feeds are returned.
+func (d *Discoverer) Discover(ctx context.Context, pageURL string) ([]Feed, error) {
+	page, err := url.Parse(strings.TrimSpace(pageURL))
+	if err != nil || (page.Scheme != "http" && page.Scheme != "https") || page.Host == "" {
+		return nil, fmt.Errorf("invalid page url %q", pageURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return nil, fmt.Errorf("page %s returned status %d", page, resp.StatusCode)
+	}
+
+	// Redirects change the base of relative links.
+	base := resp.Request.URL
+	candidates := []link{{url: base.String()}}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		// The page is not a feed: only the links it advertises and common paths are left.
+		candidates = feedLinks(io.LimitReader(resp.Body, maxPageSize), base)
+	}
+	for _, p := range commonPaths {
+		candidates = append(candidates, link{url: base.ResolveReference(&url.URL{Path: p}).String()})
+	}
+
+	return d.validate(ctx, unique(candidates)), nil
+}
+
+// validate fetches the candidates concurrently and keeps those that parse as feeds,
+// in the order of the candidates.
+func (d *Discoverer) validate(ctx context.Context, candidates []link) []Feed {
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+
+	found := make([]*Feed, len(candidates))
+	var wg sync.WaitGroup
+	for i, c := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := rss.Fetch(ctx, d.client, c.url, rss.Validators{}, d.maxBody)
+			if err != nil {
+				return
+			}
+
+			f := Feed{URL: c.url, Title: res.Title, Items: len(res.Posts)}
+			if f.Title == "" {
+				f.Title = c.title
+			}
+			found[i] = &f
+		}()
+	}
+	wg.Wait()
+
+	feeds := []Feed{}
+	for _, f := range found {
+		if f != nil {
+			feeds = append(feeds, *f)
+		}
+	}
+	return feeds
+}
+
+// link is a candidate feed URL with the title its page gave it.
+type link struct {
+	url   string
+	title string
+}
+
+// feedLinks returns the feeds advertised by an HTML page with <link rel="alternate"> and a feed
+// type, resolved against base or against the page's <base href>.
+func feedLinks(r io.Reader, base *url.URL) []link {
+	var links []link
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, more := z.TagName()
+			attrs := make(map[string]string)
+			for more {
+				var k, v []byte
+				k, v, more = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+
+			switch atom.Lookup(name) {
+			case atom.Base:
+				if u, err := base.Parse(strings.TrimSpace(attrs["href"])); err == nil && attrs["href"] != "" {
+					base = u
+				}
+			case atom.Link:
+				if !isAlternate(attrs["rel"]) || !feedTypes[strings.ToLower(strings.TrimSpace(attrs["type"]))] {
+					continue
+				}
+				u, err := base.Parse(strings.TrimSpace(attrs["href"]))
+				if err != nil || attrs["href"] == "" || (u.Scheme != "http" && u.Scheme != "https") {
+					continue
+				}
+				links = append(links, link{url: u.String(), title: strings.TrimSpace(attrs["title"])})
+			case atom.Body:
+				// Feed links belong to the head.
+				return links
+			}
+		}
+	}
+}
+
+// isAlternate reports whether a rel attribute lists "alternate".
+func isAlternate(rel string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, "alternate") {
+			return true
+		}
+	}
+	return false
+}
+
+// unique drops repeated candidate URLs, keeping the first.
+func unique(links []link) []link {
+	seen := make(map[string]bool, len(links))
+	out := links[:0]
+	for _, l := range links {
+		if !seen[l.url] {
+			seen[l.url] = true
+			out = append(out, l)
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package discover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"news/pkg/netguard"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const homePage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Example</title>
+	<link rel="stylesheet" href="/style.css">
+	<link rel="alternate" type="application/rss+xml" title="Posts" href="/posts.rss">
+	<link rel="alternate" type="application/atom+xml" title="Comments" href="comments.atom">
+	<link rel="alternate" hreflang="en" href="/en/">
+	<link rel="alternate" type="application/rss+xml" href="javascript:alert(1)">
+</head>
+<body>
+	<link rel="alternate" type="application/rss+xml" href="/in-body.rss">
+</body>
+</html>`
+
+const postsRSS = `<rss version="2.0"><channel><title>Example posts</title>
+	<item><title>One</title><link>https://example.com/1</link></item>
+	<item><title>Two</title><link>https://example.com/2</link></item>
+</channel></rss>`
+
+const commentsAtom = `<feed xmlns="http://www.w3.org/2005/Atom">
+	<entry><title>Comment</title><link href="https://example.com/1#c1"/></entry>
+</feed>`
+
+func Test_feedLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/")
+
+	got := feedLinks(strings.NewReader(homePage), base)
+	want := []link{
+		{url: "https://example.com/posts.rss", title: "Posts"},
+		{url: "https://example.com/blog/comments.atom", title: "Comments"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("feedLinks() = %+v, want %+v", got, want)
+	}
+
+	page := `<head><base href="https://cdn.example.org/site/"><link rel="Alternate feed" type="Application/RSS+XML" href="rss"></head>`
+	got = feedLinks(strings.NewReader(page), base)
+	want = []link{{url: "https://cdn.example.org/site/rss"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("feedLinks() with <base> = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiscoverer_Discover(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/blog/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(homePage))
+		case "/posts.rss", "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(postsRSS))
+		case "/blog/comments.atom":
+			w.Header().Set("Content-Type", "application/atom+xml")
+			w.Write([]byte(commentsAtom))
+		case "/rss":
+			// A page, not a feed.
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(homePage))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	// The test server listens on loopback, which the guarded client refuses.
+	d := New(time.Second, 0)
+	d.client = &http.Client{Timeout: time.Second}
+
+	t.Run("Home page", func(t *testing.T) {
+		got, err := d.Discover(context.Background(), ts.URL+"/blog/")
+		if err != nil {
+			t.Fatalf("Discover() error = %v", err)
+		}
+		want := []Feed{
+			{URL: ts.URL + "/posts.rss", Title: "Example posts", Items: 2},
+			{URL: ts.URL + "/blog/comments.atom", Title: "Comments", Items: 1},
+			{URL: ts.URL + "/feed", Title: "Example posts", Items: 2},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Discover() = %+v, want %+v", got, want)
+		}
+	})
+	t.Run("Feed URL", func(t *testing.T) {
+		got, err := d.Discover(context.Background(), ts.URL+"/posts.rss")
+		if err != nil {
+			t.Fatalf("Discover() error = %v", err)
+		}
+		if len(got) == 0 || got[0].URL != ts.URL+"/posts.rss" {
+			t.Errorf("Discover() = %+v, want the feed itself first", got)
+		}
+	})
+	t.Run("Missing page", func(t *testing.T) {
+		_, err := d.Discover(context.Background(), ts.URL+"/missing")
+		if err == nil {
+			t.Errorf("Discover() error = nil, want error for 404")
+		}
+	})
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := d.Discover(context.Background(), "ftp://example.com")
+		if err == nil {
+			t.Errorf("Discover() error = nil, want error")
+		}
+	})
+	t.Run("Private address", func(t *testing.T) {
+		_, err := New(time.Second, 0).Discover(context.Background(), ts.URL+"/blog/")
+		if !errors.Is(err, netguard.ErrPrivateAddress) {
+			t.Errorf("Discover() error = %v, want ErrPrivateAddress", err)
+		}
+	})
+}
@@ -0,0 +1,275 @@
+// Package extract downloads article pages and extracts their main text, for feeds
+// whose items carry only a teaser.
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"news/pkg/netguard"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// maxPageSize bounds how much of an article page is read.
+const maxPageSize = 5 << 20
+
+// minTextLength is the shortest extracted text, in characters, accepted as an article body.
+const minTextLength = 200
+
+// ErrNoContent is returned when no block of the page looks like an article body.
+var ErrNoContent = errors.New("no article content found")
+
+// Extractor downloads article pages with its own timeout and rate limit, separate
+// from the feed fetcher, and extracts their main text. Article links come from feeds,
+// so pages are only downloaded from public addresses. It is safe for concurrent use.
+type Extractor struct {
+	client   *http.Client
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// New creates an Extractor that waits at most timeout for a page and downloads
+// at most perMinute pages a minute. A non-positive perMinute disables the rate limit.
+func New(timeout time.Duration, perMinute int) *Extractor {
+	e := Extractor{client: netguard.Client(timeout)}
+	if perMinute > 0 {
+		e.interval = time.Minute / time.Duration(perMinute)
+	}
+	return &e
+}
+
+// Extract downloads the article page and returns its main text, paragraphs separated
+// by blank lines.
+func (e *Extractor) Extract(ctx context.Context, url string) (string, error) {
+	err := e.wait(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch article: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return "", fmt.Errorf("article %s returned status %d", url, resp.StatusCode)
+	}
+
+	// Pages in legacy encodings are decoded by the charset of the Content-Type header,
+	// the byte order mark or the <meta> declaration, in the order browsers use.
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxPageSize), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode article: %w", err)
+	}
+
+	return Text(body)
+}
+
+// wait blocks until the rate limit allows the next download or the context is done.
+func (e *Extractor) wait(ctx context.Context) error {
+	if e.interval <= 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	now := time.Now()
+	if e.next.Before(now) {
+		e.next = now
+	}
+	delay := e.next.Sub(now)
+	e.next = e.next.Add(e.interval)
+	e.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+var (
+	positiveHint = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|story`)
+	negativeHint = regexp.MustCompile(`(?i)comment|footer|sidebar|sponsor|promo|related|share|social|menu|nav|banner|widget|subscribe|\bad\b|ads`)
+)
+
+// skipped elements never hold article text.
+var skipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Button:   true,
+}
+
+// Text extracts the main text of an HTML page in the manner of Readability: every
+// paragraph scores its parent and, at half weight, its grandparent by length and commas;
+// class and id hints and link density adjust the scores, and the paragraphs of the
+// best-scoring block make up the text.
+func Text(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse article page: %w", err)
+	}
+
+	scores := make(map[*html.Node]float64)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && skipped[n.DataAtom] {
+			return
+		}
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre) {
+			scoreParagraph(n, scores)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, s := range scores {
+		s = (s + classWeight(n)) * (1 - linkDensity(n))
+		if best == nil || s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	if best == nil {
+		return "", ErrNoContent
+	}
+
+	var paragraphs []string
+	collectParagraphs(best, &paragraphs)
+	text := strings.Join(paragraphs, "\n\n")
+	if utf8.RuneCountInString(text) < minTextLength {
+		return "", ErrNoContent
+	}
+	return text, nil
+}
+
+// scoreParagraph adds the paragraph's score to its parent and half of it to its grandparent.
+// Short paragraphs are ignored.
+func scoreParagraph(p *html.Node, scores map[*html.Node]float64) {
+	text := nodeText(p)
+	length := utf8.RuneCountInString(text)
+	if length < 25 || p.Parent == nil {
+		return
+	}
+
+	score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(length)/100, 3)
+	scores[p.Parent] += score
+	if gp := p.Parent.Parent; gp != nil && gp.Type == html.ElementNode {
+		scores[gp] += score / 2
+	}
+}
+
+// classWeight rewards and penalizes blocks by the hints in their class and id.
+func classWeight(n *html.Node) float64 {
+	var w float64
+	for _, a := range n.Attr {
+		if a.Key != "class" && a.Key != "id" {
+			continue
+		}
+		if negativeHint.MatchString(a.Val) {
+			w -= 25
+		}
+		if positiveHint.MatchString(a.Val) {
+			w += 25
+		}
+	}
+	if n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		w += 25
+	}
+	return w
+}
+
+// linkDensity returns the share of the block's text that sits inside links.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(nodeText(n))
+	if total == 0 {
+		return 1
+	}
+
+	var linked int
+	var walk func(c *html.Node)
+	walk = func(c *html.Node) {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(nodeText(c))
+			return
+		}
+		for cc := c.FirstChild; cc != nil; cc = cc.NextSibling {
+			walk(cc)
+		}
+	}
+	walk(n)
+	return float64(linked) / float64(total)
+}
+
+// collectParagraphs appends the text of the block's paragraphs, headings and list items.
+func collectParagraphs(n *html.Node, out *[]string) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || skipped[c.DataAtom] {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.P, atom.Pre, atom.H2, atom.H3, atom.H4, atom.Li, atom.Blockquote:
+			if text := nodeText(c); text != "" {
+				*out = append(*out, text)
+			}
+		default:
+			collectParagraphs(c, out)
+		}
+	}
+}
+
+// nodeText returns the text of the node with whitespace collapsed, skipping non-content elements.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(c *html.Node)
+	walk = func(c *html.Node) {
+		switch {
+		case c.Type == html.TextNode:
+			b.WriteString(c.Data)
+			b.WriteByte(' ')
+		case c.Type == html.ElementNode && skipped[c.DataAtom]:
+			return
+		}
+		for cc := c.FirstChild; cc != nil; cc = cc.NextSibling {
+			walk(cc)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,163 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html>
+<head><title>Story</title><script>var tracking = "a, b, c, d, e, f, g";</script></head>
+<body>
+<header><nav><a href="/">Home</a> <a href="/news">News</a></nav></header>
+<div class="sidebar">
+	<p>Popular: <a href="/1">one, two, three</a>, <a href="/2">four, five, six</a>, <a href="/3">seven</a></p>
+</div>
+<div class="article-content">
+	<h2>The first heading</h2>
+	<p>The city council approved the new budget on Monday, allocating funds for roads, schools and parks.</p>
+	<p>Officials said the plan, which took months to prepare, would be reviewed again in the autumn.</p>
+	<script>document.write("ad, ad, ad")</script>
+	<p>Residents, who attended the meeting in large numbers, welcomed the decision but asked for more transparency.</p>
+</div>
+<div class="comments">
+	<p>Great article, thanks, really, really good!</p>
+</div>
+<footer><p>Copyright, all rights reserved, 2024, Example Media, Inc.</p></footer>
+</body>
+</html>`
+
+func TestText(t *testing.T) {
+	got, err := Text(strings.NewReader(articlePage))
+	if err != nil {
+		t.Fatalf("Text() error = %v", err)
+	}
+
+	want := "The first heading\n\n" +
+		"The city council approved the new budget on Monday, allocating funds for roads, schools and parks.\n\n" +
+		"Officials said the plan, which took months to prepare, would be reviewed again in the autumn.\n\n" +
+		"Residents, who attended the meeting in large numbers, welcomed the decision but asked for more transparency."
+	if got != want {
+		t.Fatalf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestText_NoContent(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+	}{
+		{
+			name: "Empty page",
+			page: "",
+		},
+		{
+			name: "Only navigation",
+			page: `<html><body><nav><p>Home, news, sports, weather, culture, contacts</p></nav></body></html>`,
+		},
+		{
+			name: "Too short",
+			page: `<html><body><div><p>Read the full story on our website, please.</p></div></body></html>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Text(strings.NewReader(tt.page))
+			if !errors.Is(err, ErrNoContent) {
+				t.Fatalf("Text() error = %v, want ErrNoContent", err)
+			}
+		})
+	}
+}
+
+func TestExtractor_Extract(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(articlePage))
+	}))
+	defer ts.Close()
+
+	e := New(time.Second, 0)
+	e.client = &http.Client{Timeout: time.Second}
+
+	got, err := e.Extract(context.Background(), ts.URL+"/story")
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if !strings.HasPrefix(got, "The first heading\n\n") {
+		t.Fatalf("Extract() = %q, want the article text", got)
+	}
+
+	_, err = e.Extract(context.Background(), ts.URL+"/missing")
+	if err == nil {
+		t.Fatalf("Extract() error = nil, want error for 404")
+	}
+}
+
+func TestExtractor_ExtractCharset(t *testing.T) {
+	const text = "Городской совет утвердил новый бюджет в понедельник, выделив средства на дороги, школы и парки. " +
+		"Чиновники сказали, что план, на подготовку которого ушли месяцы, будет пересмотрен осенью. " +
+		"Жители пришли на заседание и попросили больше открытости."
+	page, err := charmap.Windows1251.NewEncoder().String(`<html><head><meta charset="windows-1251"></head>` +
+		`<body><div class="article"><p>` + text + `</p></div></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/header" {
+			w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		} else {
+			w.Header().Set("Content-Type", "text/html")
+		}
+		w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	e := New(time.Second, 0)
+	e.client = &http.Client{Timeout: time.Second}
+	for _, path := range []string{"/header", "/meta"} {
+		got, err := e.Extract(context.Background(), ts.URL+path)
+		if err != nil {
+			t.Fatalf("Extract(%s) error = %v", path, err)
+		}
+		if got != text {
+			t.Fatalf("Extract(%s) = %q, want %q", path, got, text)
+		}
+	}
+}
+
+func TestExtractor_RateLimit(t *testing.T) {
+	e := New(time.Second, 600) // one page every 100ms
+	e.client = &http.Client{Timeout: time.Second}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := e.wait(context.Background())
+		if err != nil {
+			t.Fatalf("wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("3 waits took %v, want at least 200ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.wait(context.Background())
+	err := e.wait(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait() error = %v, want context.Canceled", err)
+	}
+}
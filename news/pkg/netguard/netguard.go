@@ -0,0 +1,99 @@
+// Package netguard keeps requests to addresses that come from users, such as feed and page
+// URLs, away from the internal network.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when an address is loopback, private, link-local or otherwise
+// not reachable on the internet.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// nat64 is the well-known NAT64 prefix; its addresses embed an IPv4 address in the last
+// 32 bits and reach whatever that address reaches.
+var nat64 = netip.MustParsePrefix("64:ff9b::/96")
+
+// special are the ranges of the IANA IPv4 and IPv6 special-purpose address registries that are
+// not globally reachable, along with multicast and the reserved 240.0.0.0/4.
+var special = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("10.0.0.0/8"),      // private use
+	netip.MustParsePrefix("100.64.0.0/10"),   // shared address space (carrier-grade NAT)
+	netip.MustParsePrefix("127.0.0.0/8"),     // loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // link local
+	netip.MustParsePrefix("172.16.0.0/12"),   // private use
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation (TEST-NET-1)
+	netip.MustParsePrefix("192.88.99.0/24"),  // 6to4 relay anycast, deprecated
+	netip.MustParsePrefix("192.168.0.0/16"),  // private use
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation (TEST-NET-2)
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation (TEST-NET-3)
+	netip.MustParsePrefix("224.0.0.0/4"),     // multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, including limited broadcast
+
+	netip.MustParsePrefix("::/96"),          // unspecified, loopback and IPv4-compatible
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use IPv4/IPv6 translation
+	netip.MustParsePrefix("100::/64"),       // discard-only
+	netip.MustParsePrefix("2001::/23"),      // IETF protocol assignments, including Teredo
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+	netip.MustParsePrefix("2002::/16"),      // 6to4
+	netip.MustParsePrefix("3fff::/20"),      // documentation
+	netip.MustParsePrefix("fc00::/7"),       // unique local
+	netip.MustParsePrefix("fe80::/10"),      // link local
+	netip.MustParsePrefix("fec0::/10"),      // site local, deprecated
+	netip.MustParsePrefix("ff00::/8"),       // multicast
+}
+
+// Public reports whether the address is reachable on the internet rather than falling in one of
+// the special-purpose ranges. IPv4-mapped addresses and addresses under the NAT64 prefix are
+// judged by the IPv4 address they carry.
+func Public(addr netip.Addr) bool {
+	addr = addr.WithZone("").Unmap()
+	if nat64.Contains(addr) {
+		b := addr.As16()
+		addr = netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})
+	}
+	if !addr.IsValid() {
+		return false
+	}
+
+	for _, p := range special {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Control refuses connections to non-public addresses. Used as net.Dialer.Control, it runs
+// after name resolution on the address actually dialed, so neither DNS names nor redirects can
+// lead a request to the internal network.
+func Control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !Public(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// Client returns an HTTP client with the timeout that only connects to public addresses.
+// Proxies from the environment are not used, as they would hide the addresses reached.
+func Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: Control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
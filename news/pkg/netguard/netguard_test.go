@@ -0,0 +1,70 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::5db8:d822", true}, // NAT64 of 93.184.216.34
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.5", false},
+		{"172.16.3.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"192.0.0.170", false},
+		{"198.18.0.1", false},
+		{"203.0.113.7", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"fe80::1", false},
+		{"fe80::1%eth0", false},
+		{"fd00::1", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::7f00:1", false},    // NAT64 of 127.0.0.1
+		{"64:ff9b::a9fe:a9fe", false}, // NAT64 of 169.254.169.254
+		{"64:ff9b:1::1", false},
+		{"2001:db8::1", false},
+		{"2002:7f00:1::", false},
+		{"ff02::1", false},
+	}
+	for _, tt := range tests {
+		if got := Public(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("Public(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Client(time.Second).Do(req)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Do() error = %v, want ErrPrivateAddress", err)
+	}
+}
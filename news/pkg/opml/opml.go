@@ -0,0 +1,180 @@
+// Package opml reads and writes feed lists in OPML, the format feed readers import and export.
+// Folders of outlines are categories: a feed in folder "Go" inside "Tech" has category "Tech/Go".
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feed is a feed outline of a list.
+type Feed struct {
+	URL      string
+	Title    string
+	Category string // slash-delimited folder path, empty for top level feeds
+}
+
+// document is the OPML 1.0/2.0 document; only the parts feed lists use are kept.
+type document struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Head    head      `xml:"head"`
+	Body    []outline `xml:"body>outline"`
+}
+
+// head is the document head with the list title.
+type head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+// outline is a feed when it has an xmlUrl and a folder of outlines otherwise.
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	Category string    `xml:"category,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+// Parse reads the feeds of an OPML document in document order. Outlines without an http(s)
+// xmlUrl are folders or links and are skipped; a feed listed twice is kept once.
+// A feed outside any folder takes its category from the first path of its category attribute.
+func Parse(r io.Reader) ([]Feed, error) {
+	var doc document
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	if err := d.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode OPML: %w", err)
+	}
+
+	var feeds []Feed
+	seen := make(map[string]bool)
+	var walk func(outlines []outline, folder []string)
+	walk = func(outlines []outline, folder []string) {
+		for _, o := range outlines {
+			name := strings.TrimSpace(o.Text)
+			if name == "" {
+				name = strings.TrimSpace(o.Title)
+			}
+
+			if o.XMLURL == "" {
+				// A folder; unnamed ones do not add to the path.
+				path := folder
+				if name != "" {
+					path = append(folder[:len(folder):len(folder)], cleanSegment(name))
+				}
+				walk(o.Outlines, path)
+				continue
+			}
+
+			u, err := url.Parse(strings.TrimSpace(o.XMLURL))
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || seen[u.String()] {
+				continue
+			}
+			seen[u.String()] = true
+			if name == u.String() {
+				// Written for want of a title, see Write.
+				name = ""
+			}
+
+			category := strings.Join(folder, "/")
+			if category == "" {
+				category = attrCategory(o.Category)
+			}
+			feeds = append(feeds, Feed{URL: u.String(), Title: name, Category: category})
+		}
+	}
+	walk(doc.Body, nil)
+
+	return feeds, nil
+}
+
+// Write writes the feeds as an OPML 2.0 document titled title, with a folder outline
+// for every category. Folders come first, sorted by name; feeds keep their order.
+func Write(w io.Writer, title string, feeds []Feed) error {
+	root := &outline{}
+	for _, f := range feeds {
+		parent := root
+		for _, name := range splitCategory(f.Category) {
+			parent = folder(parent, name)
+		}
+		text := f.Title
+		if text == "" {
+			text = f.URL
+		}
+		parent.Outlines = append(parent.Outlines, outline{Text: text, Title: f.Title, Type: "rss", XMLURL: f.URL})
+	}
+	sortFolders(root)
+
+	doc := document{
+		Version: "2.0",
+		Head:    head{Title: title, DateCreated: time.Now().UTC().Format(time.RFC1123)},
+		Body:    root.Outlines,
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode OPML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// folder returns the folder outline named name of parent, adding it if needed.
+func folder(parent *outline, name string) *outline {
+	for i := range parent.Outlines {
+		if o := &parent.Outlines[i]; o.XMLURL == "" && o.Text == name {
+			return o
+		}
+	}
+	parent.Outlines = append(parent.Outlines, outline{Text: name})
+	return &parent.Outlines[len(parent.Outlines)-1]
+}
+
+// sortFolders moves the folders of o before its feeds, sorted by name, down the tree.
+func sortFolders(o *outline) {
+	sort.SliceStable(o.Outlines, func(i, j int) bool {
+		a, b := o.Outlines[i], o.Outlines[j]
+		if (a.XMLURL == "") != (b.XMLURL == "") {
+			return a.XMLURL == ""
+		}
+		return a.XMLURL == "" && a.Text < b.Text
+	})
+	for i := range o.Outlines {
+		sortFolders(&o.Outlines[i])
+	}
+}
+
+// attrCategory returns the first path of an OPML 2.0 category attribute, a comma-separated
+// list of slash-delimited paths such as "/Tech/Go,/Work".
+func attrCategory(attr string) string {
+	first, _, _ := strings.Cut(attr, ",")
+	return strings.Join(splitCategory(first), "/")
+}
+
+// splitCategory splits a category into its folder names, dropping empty ones.
+func splitCategory(category string) []string {
+	var names []string
+	for _, name := range strings.Split(category, "/") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// cleanSegment makes a folder name usable in a category path.
+func cleanSegment(name string) string {
+	return strings.TrimSpace(strings.ReplaceAll(name, "/", "-"))
+}
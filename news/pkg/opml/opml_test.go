@@ -0,0 +1,83 @@
+package opml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const readerExport = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+<head><title>Subscriptions</title></head>
+<body>
+	<outline text="Tech" title="Tech">
+		<outline text="Go">
+			<outline type="rss" text="The Go Blog" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog/"/>
+			<outline type="rss" text="Golang Weekly" xmlUrl="https://cprss.s3.amazonaws.com/golangweekly.com.xml"/>
+		</outline>
+		<outline type="rss" title="Habr" xmlUrl=" https://habr.com/ru/rss/all/all/?fl=ru "/>
+	</outline>
+	<outline text="">
+		<outline type="rss" text="vc.ru" xmlUrl="https://vc.ru/rss" category="/News/Business,/Other"/>
+	</outline>
+	<outline type="link" text="Homepage" url="https://example.com"/>
+	<outline type="rss" text="Insecure" xmlUrl="javascript:alert(1)"/>
+	<outline type="rss" text="Again" xmlUrl="https://go.dev/blog/feed.atom"/>
+</body>
+</opml>`
+
+func TestParse(t *testing.T) {
+	got, err := Parse(strings.NewReader(readerExport))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Feed{
+		{URL: "https://go.dev/blog/feed.atom", Title: "The Go Blog", Category: "Tech/Go"},
+		{URL: "https://cprss.s3.amazonaws.com/golangweekly.com.xml", Title: "Golang Weekly", Category: "Tech/Go"},
+		{URL: "https://habr.com/ru/rss/all/all/?fl=ru", Title: "Habr", Category: "Tech"},
+		{URL: "https://vc.ru/rss", Title: "vc.ru", Category: "News/Business"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+
+	if _, err := Parse(strings.NewReader(`<rss version="2.0"><channel></channel></rss>`)); err == nil {
+		t.Errorf("Parse() of an RSS document error = nil, want error")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	feeds := []Feed{
+		{URL: "https://vc.ru/rss", Title: "vc.ru"},
+		{URL: "https://go.dev/blog/feed.atom", Title: "The Go Blog", Category: "Tech/Go"},
+		{URL: "https://habr.com/ru/rss/all/all/?fl=ru", Category: "Tech"},
+		{URL: "https://cprss.s3.amazonaws.com/golangweekly.com.xml", Title: "Golang & Weekly", Category: "Tech/Go"},
+		{URL: "https://example.com/a.rss", Title: "A", Category: "Another"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "News feeds", feeds); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	for _, s := range []string{`<opml version="2.0">`, `<title>News feeds</title>`, `text="Golang &amp; Weekly"`, `text="https://habr.com/ru/rss/all/all/?fl=ru"`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Write() output lacks %s:\n%s", s, buf.String())
+		}
+	}
+	if a, tech, vc := strings.Index(buf.String(), `text="Another"`), strings.Index(buf.String(), `text="Tech"`), strings.Index(buf.String(), `text="vc.ru"`); a > tech || tech > vc {
+		t.Errorf("Write() must list folders by name before feeds:\n%s", buf.String())
+	}
+
+	// Written lists read back the same, apart from the order of folders.
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse() of written list error = %v", err)
+	}
+	want := []Feed{feeds[4], feeds[1], feeds[3], feeds[2], feeds[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() of written list = %+v, want %+v", got, want)
+	}
+}
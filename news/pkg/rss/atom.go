@@ -0,0 +1,135 @@
+package rss
+
+import (
+	"encoding/xml"
+	"fmt"
+	"news/pkg/storage"
+	"strings"
+)
+
+// Internal structures for unpacking Atom 1.0 XML.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Authors    []atomPerson   `xml:"author"`
+	Title      atomText       `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+	mediaRSS
+}
+
+// atomPerson is an Atom author.
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+// atomCategory is an Atom category: a term with an optional human-readable label.
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// atomText is an Atom text construct that may hold plain text, escaped HTML or inline XHTML.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String returns the text construct body; inline XHTML is returned as markup.
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return t.Inner
+	}
+	return t.Text
+}
+
+// alternateLink returns the rel="alternate" link, falling back to the first link.
+func alternateLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// enclosureLinks converts the rel="enclosure" links to media.
+func enclosureLinks(links []atomLink) []storage.Media {
+	var out []storage.Media
+	for _, l := range links {
+		if l.Rel == "enclosure" {
+			out = append(out, storage.Media{URL: l.Href, Type: l.Type, Length: parseSize(l.Length)})
+		}
+	}
+	return out
+}
+
+// categoryTerms returns the category labels, falling back to terms.
+func categoryTerms(categories []atomCategory) []string {
+	var out []string
+	for _, c := range categories {
+		if c.Label != "" {
+			out = append(out, c.Label)
+		} else {
+			out = append(out, c.Term)
+		}
+	}
+	return out
+}
+
+// decodeAtom converts an Atom 1.0 document to posts.
+func decodeAtom(d *xml.Decoder, root xml.StartElement) (Result, error) {
+	var f atomFeed
+	err := d.DecodeElement(&f, &root)
+	if err != nil {
+		return Result{}, fmt.Errorf("XML unmarshal failed: %v", err)
+	}
+
+	data := make([]storage.Post, 0, len(f.Entries))
+	for _, e := range f.Entries {
+		content := e.Content.String()
+		if content == "" {
+			content = e.Summary.String()
+		}
+
+		date := e.Published
+		if date == "" {
+			date = e.Updated
+		}
+
+		p := newPost(e.Title.String(), content, alternateLink(e.Links), date, categoryTerms(e.Categories),
+			append(enclosureLinks(e.Links), e.media()...)...)
+		if len(e.Authors) > 0 {
+			p.Author = authorName(e.Authors[0].Name)
+		}
+		p.GUID = strings.TrimSpace(e.ID)
+		data = append(data, p)
+	}
+
+	return Result{
+		Title: strings.TrimSpace(f.Title),
+		Link:  strings.TrimSpace(alternateLink(f.Links)),
+		Posts: data,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package rss
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// utf8Reader returns the feed document transcoded to UTF-8 when its charset is given by a byte
+// order mark or, failing that, by the charset parameter of the Content-Type, and reports whether
+// it was. Otherwise the document is returned as is and the XML decoder honours the encoding
+// declared in the prolog, see charsetReader; documents without any are assumed to be UTF-8.
+func utf8Reader(r *bufio.Reader, contentType string) (io.Reader, bool, error) {
+	bom, _ := r.Peek(3)
+	switch {
+	case bytes.HasPrefix(bom, []byte{0xEF, 0xBB, 0xBF}):
+		r.Discard(3)
+		return r, true, nil
+	case bytes.HasPrefix(bom, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Reader(r), true, nil
+	case bytes.HasPrefix(bom, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Reader(r), true, nil
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return r, false, nil
+	}
+
+	enc, err := lookupCharset(params["charset"])
+	if err != nil {
+		return nil, false, err
+	}
+	if enc == nil {
+		return r, true, nil
+	}
+	return enc.NewDecoder().Reader(r), true, nil
+}
+
+// charsetReader returns the CharsetReader of the XML decoder. The encoding declared in the
+// prolog is ignored when the document has already been transcoded by utf8Reader.
+func charsetReader(transcoded bool) func(string, io.Reader) (io.Reader, error) {
+	return func(charset string, input io.Reader) (io.Reader, error) {
+		if transcoded {
+			return input, nil
+		}
+
+		enc, err := lookupCharset(charset)
+		if err != nil {
+			return nil, err
+		}
+		if enc == nil {
+			return input, nil
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+}
+
+// lookupCharset returns the encoding named by a charset label, or nil for UTF-8.
+// Labels are resolved as browsers do, so windows-1251, cp1251 and koi8-r are all known.
+func lookupCharset(charset string) (encoding.Encoding, error) {
+	name := strings.ToLower(strings.TrimSpace(charset))
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", name)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+	return enc, nil
+}
@@ -0,0 +1,201 @@
+package rss
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dateLayouts lists the feed date formats accepted by parseDate. They apply to dates
+// normalized by normalizeDate: no weekday, no commas and numeric zones. Day numbers and
+// hours may have one digit, seconds may be missing, and fractional seconds are accepted
+// after the seconds. Dates without a zone are taken as UTC.
+var dateLayouts = []string{
+	// RFC 822, RFC 1123 and RFC 850 with four- and two-digit years.
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 06 15:04:05",
+	"2 Jan 06 15:04",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04 -0700",
+	"2 January 2006 15:04:05",
+	"2 January 2006 15:04",
+	"2-Jan-06 15:04:05 -0700",
+	"2-Jan-2006 15:04:05 -0700",
+	"2 Jan 2006",
+	"2 January 2006",
+
+	// ANSI C, Unix date and Ruby date.
+	"Jan 2 15:04:05 2006",
+	"Jan 2 15:04:05 -0700 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"January 2 2006 15:04:05 -0700",
+	"Jan 2 2006",
+	"January 2 2006",
+
+	// ISO 8601 and RFC 3339, including dc:date and W3C-DTF.
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05Z07",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"20060102T150405Z0700",
+	"2006-01-02",
+}
+
+// zoneOffsets maps the zone names found in feeds to their UTC offsets.
+// time.Parse only knows the offset of the local zone's names, so names are replaced
+// by numeric offsets before parsing. The North American names are those of RFC 822, which
+// RSS dates follow, so CST is US Central rather than China Standard Time. Names used by
+// several zones outside RFC 822, such as IST (India, Ireland, Israel) and BST (British Summer,
+// Bangladesh), are left out: dates with them are not parsed and get an estimated date instead.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"MET":  "+0100",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"MSD":  "+0400",
+	"SAMT": "+0400",
+	"YEKT": "+0500",
+	"OMST": "+0600",
+	"KRAT": "+0700",
+	"IRKT": "+0800",
+	"YAKT": "+0900",
+	"VLAT": "+1000",
+	"MAGT": "+1100",
+	"PETT": "+1200",
+	"ICT":  "+0700",
+	"HKT":  "+0800",
+	"SGT":  "+0800",
+	"AWST": "+0800",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"ACST": "+0930",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// zoneOffset matches a zone given as a numeric offset, possibly from UTC or GMT, such as
+// +03:00, GMT+3 or UTC+0530.
+var zoneOffset = regexp.MustCompile(`^(?i:UTC|GMT)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// comment matches a parenthesized comment of a date, such as "(MSK)" or "(Moscow Standard Time)";
+// an unclosed one runs to the end.
+var comment = regexp.MustCompile(`\([^)]*\)?`)
+
+// localMonths maps the month names of localized feeds to the English abbreviations of the layouts.
+// Russian feeds use the genitive ("2 января") and sometimes the nominative ("январь") and short forms.
+var localMonths = map[string]string{
+	"января": "Jan", "январь": "Jan", "янв": "Jan",
+	"февраля": "Feb", "февраль": "Feb", "фев": "Feb",
+	"марта": "Mar", "март": "Mar", "мар": "Mar",
+	"апреля": "Apr", "апрель": "Apr", "апр": "Apr",
+	"мая": "May", "май": "May",
+	"июня": "Jun", "июнь": "Jun", "июн": "Jun",
+	"июля": "Jul", "июль": "Jul", "июл": "Jul",
+	"августа": "Aug", "август": "Aug", "авг": "Aug",
+	"сентября": "Sep", "сентябрь": "Sep", "сен": "Sep", "сент": "Sep",
+	"октября": "Oct", "октябрь": "Oct", "окт": "Oct",
+	"ноября": "Nov", "ноябрь": "Nov", "ноя": "Nov", "нояб": "Nov",
+	"декабря": "Dec", "декабрь": "Dec", "дек": "Dec",
+}
+
+// parseDate parses a feed date in one of many real-world formats and returns it in UTC.
+// It reports false when the date is empty or not understood.
+func parseDate(date string) (time.Time, bool) {
+	date = normalizeDate(date)
+	if date == "" {
+		return time.Time{}, false
+	}
+
+	for _, l := range dateLayouts {
+		t, err := time.Parse(l, date)
+		if err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizeDate reduces the variations of feed dates that the layouts cannot express:
+// it drops the weekday, commas, the Russian year mark "г." and parenthesized comments such as
+// "(Moscow Standard Time)", translates Russian month names, collapses spaces and turns zone
+// names and offsets into the -0700 form.
+func normalizeDate(date string) string {
+	fields := strings.Fields(comment.ReplaceAllString(date, " "))
+	if len(fields) > 0 && isWeekday(fields[0]) {
+		fields = fields[1:]
+	}
+
+	for i, f := range fields {
+		f = strings.Trim(f, ",")
+		if month, ok := localMonths[strings.ToLower(strings.TrimRight(f, "."))]; ok {
+			f = month
+		} else if f == "г." || f == "г" {
+			f = ""
+		} else if off, ok := zoneOffsets[strings.ToUpper(f)]; ok && i > 0 {
+			f = off
+		} else if m := zoneOffset.FindStringSubmatch(f); m != nil && i > 0 {
+			hours, minutes := m[2], m[3]
+			if len(hours) == 1 {
+				hours = "0" + hours
+			}
+			if minutes == "" {
+				minutes = "00"
+			}
+			f = m[1] + hours + minutes
+		}
+		fields[i] = f
+	}
+
+	fields = slices.DeleteFunc(fields, func(f string) bool { return f == "" })
+	return strings.Join(fields, " ")
+}
+
+// isWeekday reports whether the field is a weekday name, abbreviated or not,
+// with an optional trailing comma.
+func isWeekday(field string) bool {
+	name := strings.ToLower(strings.TrimRight(field, ",."))
+	if len(name) < 3 {
+		return false
+	}
+	for day := range weekdays {
+		if strings.HasPrefix(day, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package rss
+
+import (
+	"news/pkg/storage"
+	"testing"
+	"time"
+)
+
+func Test_parseDate(t *testing.T) {
+	tests := []struct {
+		date string
+		want time.Time
+	}{
+		{date: "Wed, 08 Dec 2001 01:02:03 GMT", want: time.Date(2001, 12, 8, 1, 2, 3, 0, time.UTC)},
+		{date: "Sat, 8 Dec 2001 01:02:03 +0000", want: time.Date(2001, 12, 8, 1, 2, 3, 0, time.UTC)},
+		{date: "Mon, 2 Jan 2006 15:04 MSK", want: time.Date(2006, 1, 2, 12, 4, 0, 0, time.UTC)},
+		{date: "Mon, 02 Jan 06 15:04:05 EST", want: time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{date: "Tuesday, 3 January 2006 10:00:00 +03:00", want: time.Date(2006, 1, 3, 7, 0, 0, 0, time.UTC)},
+		{date: "Mon, 02 Jan 2006 15:04:05 +0300 (MSK)", want: time.Date(2006, 1, 2, 12, 4, 5, 0, time.UTC)},
+		{date: "Mon, 02 Jan 2006 15:04:05 +0300 (Moscow Standard Time)", want: time.Date(2006, 1, 2, 12, 4, 5, 0, time.UTC)},
+		{date: "2 января 2024 10:00", want: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{date: "15 Мая 2024 г. 08:30:00 +0300", want: time.Date(2024, 5, 15, 5, 30, 0, 0, time.UTC)},
+		{date: "1 сентябрь 2023", want: time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{date: "02 Jan 2006 15:04:05 GMT+3", want: time.Date(2006, 1, 2, 12, 4, 5, 0, time.UTC)},
+		{date: "Monday, 02-Jan-06 15:04:05 PST", want: time.Date(2006, 1, 2, 23, 4, 5, 0, time.UTC)},
+		{date: "Mon, 02 Jan 2006 15:04:05 CST", want: time.Date(2006, 1, 2, 21, 4, 5, 0, time.UTC)}, // RFC 822 US Central
+		{date: "Mon Jan 2 15:04:05 2006", want: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{date: "Mon Jan 02 15:04:05 -0700 2006", want: time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{date: "Mon Jan  2 15:04:05 MST 2006", want: time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{date: "Jan 2, 2006", want: time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{date: "2023-05-10T10:00:00+03:00", want: time.Date(2023, 5, 10, 7, 0, 0, 0, time.UTC)},
+		{date: "2023-05-10T10:00:00.123Z", want: time.Date(2023, 5, 10, 10, 0, 0, 123000000, time.UTC)},
+		{date: "2023-05-10T10:00+0300", want: time.Date(2023, 5, 10, 7, 0, 0, 0, time.UTC)},
+		{date: "2023-05-10T10:00:00+03", want: time.Date(2023, 5, 10, 7, 0, 0, 0, time.UTC)},
+		{date: "2023-05-10 10:00:00", want: time.Date(2023, 5, 10, 10, 0, 0, 0, time.UTC)},
+		{date: "2023-05-10", want: time.Date(2023, 5, 10, 0, 0, 0, 0, time.UTC)},
+		{date: "  2023-05-10T10:00:00Z\n", want: time.Date(2023, 5, 10, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			got, ok := parseDate(tt.date)
+			if !ok || !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("parseDate(%q) = %v, %v, want %v", tt.date, got, ok, tt.want)
+			}
+		})
+	}
+
+	// Ambiguous zone names are not guessed.
+	for _, date := range []string{"", "yesterday", "Mon, 32 Dec 2001 01:02:03 GMT", "2023-13-01", "Monday",
+		"Mon, 02 Jan 2006 15:04:05 IST", "Mon, 02 Jan 2006 15:04:05 BST"} {
+		if got, ok := parseDate(date); ok || !got.IsZero() {
+			t.Errorf("parseDate(%q) = %v, %v, want failure", date, got, ok)
+		}
+	}
+}
+
+func Test_estimateDates(t *testing.T) {
+	fetched := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dated := time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)
+	posts := []storage.Post{{PubTime: dated}, {}}
+
+	estimateDates(posts, fetched)
+
+	if !posts[0].PubTime.Equal(dated) || posts[0].DateEstimated {
+		t.Errorf("dated post = %v, %v, want %v, not estimated", posts[0].PubTime, posts[0].DateEstimated, dated)
+	}
+	if !posts[1].PubTime.Equal(fetched) || !posts[1].DateEstimated {
+		t.Errorf("undated post = %v, %v, want %v, estimated", posts[1].PubTime, posts[1].DateEstimated, fetched)
+	}
+}
+
+func FuzzParseDate(f *testing.F) {
+	for _, seed := range []string{
+		"Wed, 08 Dec 2001 01:02:03 GMT",
+		"Mon, 2 Jan 2006 15:04 MSK",
+		"Mon Jan 02 15:04:05 -0700 2006",
+		"2023-05-10T10:00:00.123+03:00",
+		"02 Jan 2006 15:04:05 GMT+3 (MSK)",
+		"Tuesday, , 3 January 06",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, date string) {
+		got, ok := parseDate(date)
+		if !ok {
+			if !got.IsZero() {
+				t.Errorf("parseDate(%q) failed with %v, want zero time", date, got)
+			}
+			return
+		}
+		if got.Location() != time.UTC {
+			t.Errorf("parseDate(%q) = %v, want UTC", date, got)
+		}
+
+		// The dates we produce must parse back to the same instant.
+		for _, layout := range []string{time.RFC1123Z, time.RFC3339Nano} {
+			again, ok := parseDate(got.Format(layout))
+			if got.Year() >= 1000 && got.Year() <= 9999 && (!ok || !again.Equal(got.Truncate(precision(layout)))) {
+				t.Errorf("parseDate(%q) = %v, %v, want %v", got.Format(layout), again, ok, got)
+			}
+		}
+	})
+}
+
+// precision is the finest unit a layout keeps.
+func precision(layout string) time.Duration {
+	if layout == time.RFC3339Nano {
+		return time.Nanosecond
+	}
+	return time.Second
+}
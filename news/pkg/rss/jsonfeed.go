@@ -0,0 +1,93 @@
+package rss
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"news/pkg/storage"
+	"strings"
+)
+
+// Internal structures for unpacking JSON Feed 1.1.
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	ContentText   string           `json:"content_text"`
+	Summary       string           `json:"summary"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Image         string           `json:"image"`
+	BannerImage   string           `json:"banner_image"`
+	Tags          []string         `json:"tags"`
+	Author        jsonFeedAuthor   `json:"author"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Attachments   []struct {
+		URL      string `json:"url"`
+		MIMEType string `json:"mime_type"`
+		Size     int64  `json:"size_in_bytes"`
+	} `json:"attachments"`
+}
+
+// jsonFeedAuthor is a JSON Feed author, listed in "authors" since version 1.1 and
+// given as a single "author" before.
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// decodeJSONFeed converts a JSON Feed document to posts.
+func decodeJSONFeed(r io.Reader) (Result, error) {
+	var f jsonFeed
+	err := json.NewDecoder(r).Decode(&f)
+	if err != nil {
+		return Result{}, fmt.Errorf("JSON unmarshal failed: %v", err)
+	}
+
+	data := make([]storage.Post, 0, len(f.Items))
+	for _, it := range f.Items {
+		content := it.ContentHTML
+		if content == "" {
+			content = it.ContentText
+		}
+		if content == "" {
+			content = it.Summary
+		}
+
+		date := it.DatePublished
+		if date == "" {
+			date = it.DateModified
+		}
+
+		var media []storage.Media
+		for _, a := range it.Attachments {
+			media = append(media, storage.Media{URL: a.URL, Type: a.MIMEType, Length: a.Size})
+		}
+		for _, img := range []string{it.Image, it.BannerImage} {
+			if img != "" {
+				media = append(media, storage.Media{URL: img, Type: guessType(img, "image")})
+			}
+		}
+
+		p := newPost(it.Title, content, it.URL, date, it.Tags, media...)
+		p.Author = authorName(it.Author.Name)
+		if len(it.Authors) > 0 {
+			p.Author = authorName(it.Authors[0].Name)
+		}
+		p.GUID = strings.TrimSpace(it.ID)
+		data = append(data, p)
+	}
+
+	return Result{
+		Title: strings.TrimSpace(f.Title),
+		Link:  strings.TrimSpace(f.HomePageURL),
+		Posts: data,
+	}, nil
+}
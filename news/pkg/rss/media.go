@@ -0,0 +1,171 @@
+package rss
+
+import (
+	"mime"
+	"net/url"
+	"news/pkg/storage"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Internal structures for unpacking enclosures and Media RSS elements.
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type mediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Medium   string `xml:"medium,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Width    string `xml:"width,attr"`
+	Height   string `xml:"height,attr"`
+}
+
+type mediaThumbnail struct {
+	URL    string `xml:"url,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+}
+
+type mediaGroup struct {
+	Contents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+// mediaRSS holds the Media RSS elements of an item or entry, directly or inside media:group.
+type mediaRSS struct {
+	Contents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Groups     []mediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+// media converts the Media RSS elements, contents before thumbnails.
+func (m mediaRSS) media() []storage.Media {
+	contents, thumbnails := m.Contents, m.Thumbnails
+	for _, g := range m.Groups {
+		contents = append(contents, g.Contents...)
+		thumbnails = append(thumbnails, g.Thumbnails...)
+	}
+
+	var out []storage.Media
+	for _, c := range contents {
+		typ := c.Type
+		if typ == "" && c.Medium == "image" {
+			typ = guessType(c.URL, "image")
+		}
+		out = append(out, storage.Media{
+			URL:    c.URL,
+			Type:   typ,
+			Length: parseSize(c.FileSize),
+			Width:  int(parseSize(c.Width)),
+			Height: int(parseSize(c.Height)),
+		})
+	}
+	for _, t := range thumbnails {
+		out = append(out, storage.Media{
+			URL:    t.URL,
+			Type:   guessType(t.URL, "image"),
+			Width:  int(parseSize(t.Width)),
+			Height: int(parseSize(t.Height)),
+		})
+	}
+	return out
+}
+
+// enclosureMedia converts RSS enclosures.
+func enclosureMedia(encs []enclosure) []storage.Media {
+	var out []storage.Media
+	for _, e := range encs {
+		out = append(out, storage.Media{URL: e.URL, Type: e.Type, Length: parseSize(e.Length)})
+	}
+	return out
+}
+
+// imagesIn returns the images embedded in sanitized HTML, whose URLs are already absolute.
+func imagesIn(sanitized string) []storage.Media {
+	var out []storage.Media
+	z := html.NewTokenizer(strings.NewReader(sanitized))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		if t.DataAtom != atom.Img {
+			continue
+		}
+		var m storage.Media
+		for _, a := range t.Attr {
+			switch a.Key {
+			case "src":
+				m.URL = a.Val
+			case "width":
+				m.Width = int(parseSize(a.Val))
+			case "height":
+				m.Height = int(parseSize(a.Val))
+			}
+		}
+		if m.URL != "" {
+			m.Type = guessType(m.URL, "image")
+			out = append(out, m)
+		}
+	}
+}
+
+// postMedia resolves the media URLs against the item link and drops media without an
+// http(s) URL and repeated URLs, keeping the first occurrence.
+func postMedia(link string, media ...[]storage.Media) []storage.Media {
+	base, err := url.Parse(link)
+	if err != nil || !base.IsAbs() {
+		base = nil
+	}
+
+	var out []storage.Media
+	seen := make(map[string]bool)
+	for _, list := range media {
+		for _, m := range list {
+			u, ok := safeURL(m.URL, base, false)
+			if !ok || seen[u] {
+				continue
+			}
+			seen[u] = true
+			m.URL = u
+			m.Type = strings.ToLower(strings.TrimSpace(m.Type))
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// guessType returns the MIME type for the URL's file extension if it is of the given kind,
+// or kind/* otherwise.
+func guessType(rawURL, kind string) string {
+	u, err := url.Parse(rawURL)
+	if err == nil {
+		typ, _, _ := mime.ParseMediaType(mime.TypeByExtension(path.Ext(u.Path)))
+		if strings.HasPrefix(typ, kind+"/") {
+			return typ
+		}
+	}
+	return kind + "/*"
+}
+
+// parseSize parses a non-negative integer attribute, returning 0 if it is missing or invalid.
+func parseSize(raw string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package rss
+
+import (
+	"news/pkg/storage"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_decodeMedia(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []storage.Media
+	}{
+		{
+			name: "RSS enclosure, Media RSS and embedded image",
+			doc: `<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+	<item>
+		<title>Episode 1</title>
+		<link>https://example.com/podcast/1</link>
+		<description><![CDATA[<p>Show notes <img src="/img/cover.png" width="640" height="360"> <img src="javascript:alert(1)"></p>]]></description>
+		<enclosure url="https://cdn.example.com/ep1.mp3" type="audio/mpeg" length="12345678"/>
+		<media:content url="https://cdn.example.com/ep1.mp4" type="video/mp4" fileSize="99" width="1280" height="720"/>
+		<media:group>
+			<media:content url="https://cdn.example.com/ep1.jpg" medium="image"/>
+			<media:thumbnail url="https://cdn.example.com/thumb.jpg" width="120" height="90"/>
+		</media:group>
+		<media:thumbnail url="https://cdn.example.com/ep1.mp3"/>
+	</item>
+</channel>
+</rss>`,
+			want: []storage.Media{
+				{URL: "https://cdn.example.com/ep1.mp3", Type: "audio/mpeg", Length: 12345678},
+				{URL: "https://cdn.example.com/ep1.mp4", Type: "video/mp4", Length: 99, Width: 1280, Height: 720},
+				{URL: "https://cdn.example.com/ep1.jpg", Type: "image/jpeg"},
+				{URL: "https://cdn.example.com/thumb.jpg", Type: "image/jpeg", Width: 120, Height: 90},
+				{URL: "https://example.com/img/cover.png", Type: "image/png", Width: 640, Height: 360},
+			},
+		},
+		{
+			name: "Atom enclosure link and YouTube-style media group",
+			doc: `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+	<entry>
+		<title>Video</title>
+		<link rel="alternate" href="https://example.com/watch/1"/>
+		<link rel="enclosure" type="audio/ogg" length="42" href="/audio/1.ogg"/>
+		<published>2024-01-15T12:00:00Z</published>
+		<media:group>
+			<media:thumbnail url="https://i.example.com/1/hq.jpg" width="480" height="360"/>
+		</media:group>
+	</entry>
+</feed>`,
+			want: []storage.Media{
+				{URL: "https://example.com/audio/1.ogg", Type: "audio/ogg", Length: 42},
+				{URL: "https://i.example.com/1/hq.jpg", Type: "image/jpeg", Width: 480, Height: 360},
+			},
+		},
+		{
+			name: "JSON Feed attachments and image",
+			doc: `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"items": [{
+		"url": "https://example.net/1",
+		"title": "Item",
+		"content_text": "Text",
+		"image": "https://example.net/1.webp",
+		"attachments": [{"url": "https://example.net/1.m4a", "mime_type": "audio/x-m4a", "size_in_bytes": 100}]
+	}]
+}`,
+			want: []storage.Media{
+				{URL: "https://example.net/1.m4a", Type: "audio/x-m4a", Length: 100},
+				{URL: "https://example.net/1.webp", Type: "image/webp"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := decode(strings.NewReader(tt.doc), "")
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if len(res.Posts) != 1 {
+				t.Fatalf("decode() = %d posts, want 1", len(res.Posts))
+			}
+			if got := res.Posts[0].Media; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Media = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package rss
+
+import (
+	"encoding/xml"
+	"fmt"
+	"news/pkg/storage"
+	"strings"
+)
+
+// Internal structures for unpacking RSS 1.0 (RDF) XML.
+// Unlike RSS 2.0, items are siblings of the channel element.
+type rdfFeed struct {
+	XMLName xml.Name  `xml:"RDF"`
+	Channel channel   `xml:"channel"`
+	Items   []rdfItem `xml:"item"`
+}
+
+type rdfItem struct {
+	About       string   `xml:"about,attr"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Title       string   `xml:"title"`
+	Description string   `xml:"description"`
+	Link        links    `xml:"link"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+// decodeRDF converts an RSS 1.0 (RDF) document to posts.
+func decodeRDF(d *xml.Decoder, root xml.StartElement) (Result, error) {
+	var f rdfFeed
+	err := d.DecodeElement(&f, &root)
+	if err != nil {
+		return Result{}, fmt.Errorf("XML unmarshal failed: %v", err)
+	}
+
+	data := make([]storage.Post, 0, len(f.Items))
+	for _, it := range f.Items {
+		p := newPost(it.Title, it.Description, it.Link.String(), it.Date, it.Subjects)
+		p.Author = authorName(it.Creator)
+		p.GUID = strings.TrimSpace(it.About)
+		data = append(data, p)
+	}
+
+	return Result{
+		Title: strings.TrimSpace(f.Channel.Title),
+		Link:  f.Channel.Link.String(),
+		Posts: data,
+	}, nil
+}
@@ -1,16 +1,25 @@
 package rss
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"news/pkg/dedup"
 	"news/pkg/storage"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	strip "github.com/grokify/html-strip-tags-go"
 )
 
@@ -21,91 +30,387 @@ type feed struct {
 }
 
 type channel struct {
-	Title       string `xml:"title"`
-	Description string `xml:"description"`
-	Link        string `xml:"link"`
-	Items       []item `xml:"item"`
+	Title       string   `xml:"title"`
+	Description string   `xml:"description"`
+	Link        links    `xml:"link"`
+	TTL         string   `xml:"ttl"`
+	SkipHours   []string `xml:"skipHours>hour"`
+	SkipDays    []string `xml:"skipDays>day"`
+	Items       []item   `xml:"item"`
 }
 
 type item struct {
-	Title       string `xml:"title"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	Link        string `xml:"link"`
+	Title       string      `xml:"title"`
+	Description string      `xml:"description"`
+	PubDate     string      `xml:"pubDate"`
+	Date        string      `xml:"date"` // dc:date, used when there is no pubDate
+	Link        links       `xml:"link"`
+	GUID        string      `xml:"guid"`
+	Author      string      `xml:"author"`
+	Creator     string      `xml:"creator"`
+	Categories  []string    `xml:"category"`
+	Enclosures  []enclosure `xml:"enclosure"`
+	mediaRSS
 }
 
-// Parse downloads RSS from the URL, decodes the XML, and returns a Post slice.
+// rss1Namespace is the default namespace of RSS 1.0 (RDF) documents.
+const rss1Namespace = "http://purl.org/rss/1.0/"
+
+// links collects the link elements of a channel or item. A bare "link" also matches
+// <atom:link rel="self" href="..."/>, which many RSS 2.0 feeds place next to <link>, so the
+// elements are kept with their names and String picks the RSS one.
+type links []struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// String returns the text of the first link in no namespace or in the RSS 1.0 namespace.
+func (l links) String() string {
+	for _, link := range l {
+		if link.XMLName.Space == "" || link.XMLName.Space == rss1Namespace {
+			return strings.TrimSpace(link.Text)
+		}
+	}
+	return ""
+}
+
+// Validators holds the HTTP cache validators returned with a previous response.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Result is the outcome of a conditional feed fetch.
+// Title and Link describe the channel: its name and the publisher's site.
+// TTL, SkipHours and SkipDays carry the publisher's polling hints from RSS 2.0 channels;
+// RetryAfter is set when a failed response asked the client to come back later.
+type Result struct {
+	Title       string
+	Link        string
+	Posts       []storage.Post
+	Validators  Validators
+	NotModified bool
+	TTL         time.Duration
+	SkipHours   []int
+	SkipDays    []time.Weekday
+	RetryAfter  time.Duration
+}
+
+// DefaultMaxBodySize bounds the size of a feed document when no other limit is given.
+const DefaultMaxBodySize = 10 << 20
+
+// ErrBodyTooLarge is returned when a feed document exceeds its size limit.
+var ErrBodyTooLarge = errors.New("feed body too large")
+
+// acceptEncoding lists the compressions negotiated with feed servers, see decompress.
+const acceptEncoding = "br, gzip"
+
+// Parse downloads a feed from the URL, decodes it, and returns a Post slice.
+// RSS 2.0, Atom 1.0, RSS 1.0 (RDF) and JSON Feed documents are supported.
 func Parse(ctx context.Context, client *http.Client, url string) ([]storage.Post, error) {
+	res, err := Fetch(ctx, client, url, Validators{}, DefaultMaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	return res.Posts, nil
+}
+
+// Fetch performs a conditional GET of the feed using the given validators.
+// A 304 Not Modified response yields a Result with NotModified set and no posts.
+// The document is decoded as it streams in; one larger than maxBody bytes once decompressed
+// fails with ErrBodyTooLarge. A non-positive maxBody means DefaultMaxBodySize.
+func Fetch(ctx context.Context, client *http.Client, url string, v Validators, maxBody int64) (Result, error) {
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodySize
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("new request failed: %v", err)
+		return Result{}, fmt.Errorf("new request failed: %v", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
 	}
 
 	res, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("do request failed: %v", err)
+		return Result{}, fmt.Errorf("do request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	body, err := io.ReadAll(res.Body)
+	if res.StatusCode == http.StatusNotModified {
+		return Result{Validators: v, NotModified: true}, nil
+	}
+
+	body, err := decompress(res)
 	if err != nil {
-		return nil, fmt.Errorf("read body failed: %v", err)
+		return Result{}, err
 	}
 
 	if res.StatusCode > 299 {
-		limit := body
-		if len(limit) > 2048 {
-			limit = limit[:2048]
+		limit, _ := io.ReadAll(io.LimitReader(body, 2048))
+		retry := Result{RetryAfter: retryAfter(res.Header.Get("Retry-After"), time.Now())}
+		return retry, fmt.Errorf("response failed with status code: %d and body: %s", res.StatusCode, limit)
+	}
+
+	tooLarge := fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, maxBody)
+	if res.Header.Get("Content-Encoding") == "" && res.ContentLength > maxBody {
+		return Result{}, tooLarge
+	}
+
+	limited := &limitedReader{r: body, n: maxBody}
+	result, err := decode(limited, res.Header.Get("Content-Type"))
+	if limited.exceeded {
+		return Result{}, tooLarge
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	estimateDates(result.Posts, time.Now())
+
+	result.Validators = Validators{
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}
+	return result, nil
+}
+
+// decompress returns the response body decoded according to its Content-Encoding.
+// Setting Accept-Encoding turns off the transparent gzip support of http.Transport,
+// so the encodings asked for in acceptEncoding are decoded here.
+func decompress(res *http.Response) (io.Reader, error) {
+	switch enc := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		return res.Body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip body failed: %v", err)
 		}
-		return nil, fmt.Errorf("response failed with status code: %d and body: %s", res.StatusCode, limit)
+		return gz, nil
+	case "br":
+		return brotli.NewReader(res.Body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", enc)
 	}
+}
 
-	var f feed
-	err = xml.Unmarshal(body, &f)
+// limitedReader reads at most n bytes and records whether the underlying reader had more.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+	if l.n <= 0 {
+		// The limit is reached: the document fits only if nothing follows.
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			l.exceeded = true
+			return 0, ErrBodyTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// estimateDates gives the posts without a publication date the fetch time and flags the
+// date as estimated, so that they are not sorted below every dated post.
+func estimateDates(posts []storage.Post, fetched time.Time) {
+	for i := range posts {
+		if posts[i].PubTime.IsZero() {
+			posts[i].PubTime = fetched.UTC()
+			posts[i].DateEstimated = true
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// decode picks a parser by the Content-Type or, failing that, by sniffing the document,
+// and decodes the document as it is read.
+func decode(r io.Reader, contentType string) (Result, error) {
+	in, transcoded, err := utf8Reader(bufio.NewReader(r), contentType)
+	if err != nil {
+		return Result{}, err
+	}
+
+	doc := bufio.NewReader(in)
+	if isJSON(doc, contentType) {
+		return decodeJSONFeed(doc)
+	}
+
+	d := xml.NewDecoder(doc)
+	d.CharsetReader = charsetReader(transcoded)
+	root, err := rootElement(d)
 	if err != nil {
-		return nil, fmt.Errorf("XML unmarshal failed: %v", err)
+		return Result{}, fmt.Errorf("XML unmarshal failed: %v", err)
+	}
+
+	switch root.Name.Local {
+	case "rss":
+		return decodeRSS(d, root)
+	case "feed":
+		return decodeAtom(d, root)
+	case "RDF":
+		return decodeRDF(d, root)
+	default:
+		return Result{}, fmt.Errorf("unsupported feed format: <%s>", root.Name.Local)
+	}
+}
+
+// isJSON reports whether the document is a JSON Feed rather than XML.
+func isJSON(doc *bufio.Reader, contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/feed+json", "application/json":
+		return true
 	}
 
-	layouts := []string{
-		time.Layout,
-		time.ANSIC,
-		time.UnixDate,
-		time.RubyDate,
-		time.RFC822,
-		time.RFC822Z,
-		time.RFC850,
-		time.RFC1123,
-		time.RFC1123Z,
-		time.RFC3339,
+	head, _ := doc.Peek(512)
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// rootElement reads the document up to its first element and returns it.
+func rootElement(d *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se, nil
+		}
+	}
+}
+
+// decodeRSS converts an RSS 2.0 document to posts and reads the channel polling hints.
+func decodeRSS(d *xml.Decoder, root xml.StartElement) (Result, error) {
+	var f feed
+	err := d.DecodeElement(&f, &root)
+	if err != nil {
+		return Result{}, fmt.Errorf("XML unmarshal failed: %v", err)
 	}
 
 	data := make([]storage.Post, 0, len(f.Channel.Items))
 	for _, itemNode := range f.Channel.Items {
-		var p storage.Post
-		p.Title = strings.TrimSpace(itemNode.Title)
-		p.Content = strings.TrimSpace(strip.StripTags(itemNode.Description))
-		p.Link = strings.TrimSpace(itemNode.Link)
-
-		var parsed time.Time
-		var parsErr error
-		for _, l := range layouts {
-			t, err := time.Parse(l, itemNode.PubDate)
-			if err != nil {
-				parsErr = err
-			}
-			if err == nil {
-				parsed = t
-				break
-			}
+		date := itemNode.PubDate
+		if strings.TrimSpace(date) == "" {
+			date = itemNode.Date
 		}
+		p := newPost(itemNode.Title, itemNode.Description, itemNode.Link.String(), date, itemNode.Categories,
+			append(enclosureMedia(itemNode.Enclosures), itemNode.media()...)...)
+		p.Author = authorName(itemNode.Creator, itemNode.Author)
+		p.GUID = strings.TrimSpace(itemNode.GUID)
+		data = append(data, p)
+	}
 
-		if parsed.IsZero() {
-			slog.Warn("Parse: pubDate parse failed", "pubDate", itemNode.PubDate, "err", parsErr)
+	res := Result{
+		Title: strings.TrimSpace(f.Channel.Title),
+		Link:  f.Channel.Link.String(),
+		Posts: data,
+	}
+	if ttl, err := strconv.Atoi(strings.TrimSpace(f.Channel.TTL)); err == nil && ttl > 0 {
+		res.TTL = time.Duration(ttl) * time.Minute
+	}
+	for _, h := range f.Channel.SkipHours {
+		hour, err := strconv.Atoi(strings.TrimSpace(h))
+		if err == nil && hour >= 0 && hour <= 23 {
+			res.SkipHours = append(res.SkipHours, hour)
+		}
+	}
+	for _, d := range f.Channel.SkipDays {
+		if day, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]; ok {
+			res.SkipDays = append(res.SkipDays, day)
 		}
-		p.PubTime = parsed.UTC()
+	}
 
-		data = append(data, p)
+	return res, nil
+}
+
+// weekdays maps RSS skipDays names to weekdays.
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// newPost builds a Post from raw feed fields, normalizing the date; a date that cannot be
+// parsed is left zero for Fetch to estimate. The content is kept
+// both as plain text and as sanitized HTML with URLs resolved against the item link.
+// Categories become normalized tags; the item media are followed by the images embedded
+// in the content.
+func newPost(title, content, link, date string, categories []string, media ...storage.Media) storage.Post {
+	var p storage.Post
+	p.Title = strings.TrimSpace(title)
+	p.Content = strings.TrimSpace(strip.StripTags(content))
+	p.Link = dedup.NormalizeURL(link)
+	p.ContentHTML = sanitizeHTML(content, p.Link)
+	p.Media = postMedia(p.Link, media, imagesIn(p.ContentHTML))
+	p.Tags = postTags(categories)
+	p.PubTime, _ = parseDate(date)
+	if p.PubTime.IsZero() && strings.TrimSpace(date) != "" {
+		slog.Warn("Parse: pubDate parse failed", "pubDate", date)
 	}
+	return p
+}
 
-	return data, nil
+// authorName returns the first non-empty author. An RSS <author> given as
+// "editor@example.com (Jane Doe)" yields the name in parentheses.
+func authorName(authors ...string) string {
+	for _, a := range authors {
+		a = strings.Join(strings.Fields(a), " ")
+		if open := strings.Index(a, " ("); open > 0 && strings.HasSuffix(a, ")") && strings.Contains(a[:open], "@") {
+			a = a[open+2 : len(a)-1]
+		}
+		if a != "" {
+			return a
+		}
+	}
+	return ""
+}
+
+// postTags normalizes the categories into tags, dropping empty and repeated ones.
+func postTags(categories []string) []string {
+	var tags []string
+	for _, c := range categories {
+		tag := storage.NormalizeTag(c)
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
 }
@@ -1,7 +1,11 @@
 package rss
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"news/pkg/storage"
@@ -9,8 +13,97 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
 )
 
+const atomXML = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+    <title>Demo Atom</title>
+    <link href="https://example.com/"/>
+    <updated>2024-03-01T10:00:00Z</updated>
+    <entry>
+        <title>Release v1.2.0</title>
+        <link rel="self" href="https://example.com/feed/v1.2.0"/>
+        <link rel="alternate" type="text/html" href="https://example.com/releases/v1.2.0"/>
+        <id>tag:example.com,2024:v1.2.0</id>
+        <author><name>Release Bot</name></author>
+        <published>2024-03-01T10:00:00Z</published>
+        <updated>2024-03-02T10:00:00Z</updated>
+        <summary>Short summary</summary>
+        <content type="html">&lt;p&gt;Bug fixes and improvements&lt;/p&gt;</content>
+    </entry>
+    <entry>
+        <title type="text">Release v1.1.0</title>
+        <link href="https://example.com/releases/v1.1.0"/>
+        <id>tag:example.com,2024:v1.1.0</id>
+        <updated>2024-02-01T12:30:00+03:00</updated>
+        <summary>Summary only</summary>
+    </entry>
+    <entry>
+        <title>Inline XHTML</title>
+        <link href="https://example.com/xhtml"/>
+        <published>2024-01-15T12:00:00Z</published>
+        <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><b>Hello</b> world</div></content>
+    </entry>
+</feed>`
+
+const rdfXML = `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF
+    xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns="http://purl.org/rss/1.0/">
+    <channel rdf:about="https://example.org/">
+        <title>Demo RDF</title>
+        <link>https://example.org/</link>
+        <description>Demo RDF channel</description>
+        <items>
+            <rdf:Seq>
+                <rdf:li rdf:resource="https://example.org/1"/>
+                <rdf:li rdf:resource="https://example.org/2"/>
+            </rdf:Seq>
+        </items>
+    </channel>
+    <item rdf:about="https://example.org/1">
+        <title>RDF Title #1</title>
+        <link>https://example.org/1</link>
+        <description>&lt;p&gt;RDF description #1&lt;/p&gt;</description>
+        <dc:date>2023-05-10T10:00:00+03:00</dc:date>
+        <dc:creator>Jane Doe</dc:creator>
+    </item>
+    <item rdf:about="https://example.org/2">
+        <title>RDF Title #2</title>
+        <link>https://example.org/2</link>
+        <description>RDF description #2</description>
+        <dc:date>2023-05-11T08:15:00Z</dc:date>
+    </item>
+</rdf:RDF>`
+
+const jsonFeedDoc = `{
+    "version": "https://jsonfeed.org/version/1.1",
+    "title": "Demo JSON Feed",
+    "home_page_url": "https://example.net/",
+    "items": [
+        {
+            "id": "1",
+            "url": "HTTPS://Example.net/1?utm_source=feed#top",
+            "title": "JSON Title #1",
+            "content_html": "<p>JSON content #1</p>",
+            "date_published": "2024-04-01T08:00:00Z",
+            "authors": [{"name": "John Doe"}]
+        },
+        {
+            "id": "2",
+            "url": "https://example.net/2",
+            "title": "JSON Title #2",
+            "content_text": "Plain text #2",
+            "date_modified": "2024-04-02T12:00:00+03:00"
+        }
+    ]
+}`
+
 func TestParse(t *testing.T) {
 	const rssXML = `<rss version="2.0">
     <channel>
@@ -55,22 +148,28 @@ func TestParse(t *testing.T) {
 
 	post := []storage.Post{
 		{
-			Title:   "Test Title #1",
-			Content: "Test description #1",
-			PubTime: time.Date(2001, 12, 8, 01, 02, 03, 0, time.UTC),
-			Link:    "https://example.ru/ru/articles/198744/=rss",
+			Title:       "Test Title #1",
+			Content:     "Test description #1",
+			ContentHTML: "Test description #1",
+			Author:      "itcaat",
+			GUID:        "https://example.com",
+			Tags:        []string{"category_test"},
+			PubTime:     time.Date(2001, 12, 8, 01, 02, 03, 0, time.UTC),
+			Link:        "https://example.ru/ru/articles/198744/=rss",
 		},
 		{
-			Title:   "Test Title #2",
-			Content: "Test description #2",
-			PubTime: time.Date(2006, 1, 2, 22, 04, 05, 0, time.UTC),
-			Link:    "https://example.ru/ru/articles/108175/=rss",
+			Title:       "Test Title #2",
+			Content:     "Test description #2",
+			ContentHTML: "Test description #2",
+			PubTime:     time.Date(2006, 1, 2, 22, 04, 05, 0, time.UTC),
+			Link:        "https://example.ru/ru/articles/108175/=rss",
 		},
 		{
-			Title:   "Test Title #3",
-			Content: "Test description #3",
-			PubTime: time.Date(0001, 1, 1, 00, 00, 00, 00, time.UTC),
-			Link:    "https://example.ru/com/articles/1974014/=rss",
+			Title:         "Test Title #3",
+			Content:       "Test description #3",
+			ContentHTML:   "Test description #3",
+			DateEstimated: true,
+			Link:          "https://example.ru/com/articles/1974014/=rss",
 		},
 	}
 
@@ -92,15 +191,160 @@ func TestParse(t *testing.T) {
 		client := &http.Client{Timeout: 10 * time.Second}
 		ctx := context.Background()
 
+		before := time.Now()
 		got, err := Parse(ctx, client, tsOK.URL)
 		if (err != nil) != testOK.wantErr {
 			t.Errorf("Parse() error = %v, wantErr %v", err, testOK.wantErr)
 			return
 		}
+		// The undated item gets the fetch time.
+		if estimated := got[2].PubTime; estimated.Before(before.Truncate(time.Second)) || estimated.After(time.Now()) {
+			t.Errorf("Parse() estimated date = %v, want the fetch time", estimated)
+		}
+		got[2].PubTime = time.Time{}
 		if !reflect.DeepEqual(got, testOK.want) {
 			t.Errorf("Parse() = %v, want %v", got, testOK.want)
 		}
 	})
+	t.Run("Valid Atom", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(atomXML))
+		}))
+		defer ts.Close()
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		ctx := context.Background()
+
+		want := []storage.Post{
+			{
+				Title:       "Release v1.2.0",
+				Content:     "Bug fixes and improvements",
+				ContentHTML: "<p>Bug fixes and improvements</p>",
+				Author:      "Release Bot",
+				GUID:        "tag:example.com,2024:v1.2.0",
+				PubTime:     time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				Link:        "https://example.com/releases/v1.2.0",
+			},
+			{
+				Title:       "Release v1.1.0",
+				Content:     "Summary only",
+				ContentHTML: "Summary only",
+				GUID:        "tag:example.com,2024:v1.1.0",
+				PubTime:     time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC),
+				Link:        "https://example.com/releases/v1.1.0",
+			},
+			{
+				Title:       "Inline XHTML",
+				Content:     "Hello world",
+				ContentHTML: "<div><b>Hello</b> world</div>",
+				PubTime:     time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC),
+				Link:        "https://example.com/xhtml",
+			},
+		}
+
+		got, err := Parse(ctx, client, ts.URL)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse() = %v, want %v", got, want)
+		}
+	})
+	t.Run("Valid RDF", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(rdfXML))
+		}))
+		defer ts.Close()
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		ctx := context.Background()
+
+		want := []storage.Post{
+			{
+				Title:       "RDF Title #1",
+				Content:     "RDF description #1",
+				ContentHTML: "<p>RDF description #1</p>",
+				Author:      "Jane Doe",
+				GUID:        "https://example.org/1",
+				PubTime:     time.Date(2023, 5, 10, 7, 0, 0, 0, time.UTC),
+				Link:        "https://example.org/1",
+			},
+			{
+				Title:       "RDF Title #2",
+				Content:     "RDF description #2",
+				ContentHTML: "RDF description #2",
+				GUID:        "https://example.org/2",
+				PubTime:     time.Date(2023, 5, 11, 8, 15, 0, 0, time.UTC),
+				Link:        "https://example.org/2",
+			},
+		}
+
+		got, err := Parse(ctx, client, ts.URL)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse() = %v, want %v", got, want)
+		}
+	})
+	t.Run("Valid JSON Feed", func(t *testing.T) {
+		want := []storage.Post{
+			{
+				Title:       "JSON Title #1",
+				Content:     "JSON content #1",
+				ContentHTML: "<p>JSON content #1</p>",
+				Author:      "John Doe",
+				GUID:        "1",
+				PubTime:     time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC),
+				Link:        "https://example.net/1",
+			},
+			{
+				Title:       "JSON Title #2",
+				Content:     "Plain text #2",
+				ContentHTML: "Plain text #2",
+				GUID:        "2",
+				PubTime:     time.Date(2024, 4, 2, 9, 0, 0, 0, time.UTC),
+				Link:        "https://example.net/2",
+			},
+		}
+
+		for _, contentType := range []string{"application/feed+json", "text/plain"} {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(jsonFeedDoc))
+			}))
+			defer ts.Close()
+
+			client := &http.Client{Timeout: 10 * time.Second}
+			ctx := context.Background()
+
+			got, err := Parse(ctx, client, ts.URL)
+			if err != nil {
+				t.Fatalf("Parse() with %s error = %v", contentType, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Parse() with %s = %v, want %v", contentType, got, want)
+			}
+		}
+	})
+	t.Run("Unsupported format", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`<html><body>not a feed</body></html>`))
+		}))
+		defer ts.Close()
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		ctx := context.Background()
+
+		_, err := Parse(ctx, client, ts.URL)
+		if err == nil {
+			t.Errorf("Parse() error = nil, want error")
+		}
+	})
 	t.Run("Non-2xx status code", func(t *testing.T) {
 		tsErr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			longBody := strings.Repeat("err", 1000)
@@ -121,3 +365,490 @@ func TestParse(t *testing.T) {
 
 	})
 }
+
+func TestFetch(t *testing.T) {
+	const etag = `"abc"`
+	const lastModified = "Wed, 08 Dec 2021 01:02:03 GMT"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rdfXML))
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	ctx := context.Background()
+
+	t.Run("Unconditional", func(t *testing.T) {
+		got, err := Fetch(ctx, client, ts.URL, Validators{}, 0)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if got.NotModified || len(got.Posts) != 2 {
+			t.Fatalf("Fetch() = %+v, want 2 posts", got)
+		}
+		want := Validators{ETag: etag, LastModified: lastModified}
+		if got.Validators != want {
+			t.Errorf("Fetch() validators = %+v, want %+v", got.Validators, want)
+		}
+	})
+	t.Run("If-None-Match", func(t *testing.T) {
+		got, err := Fetch(ctx, client, ts.URL, Validators{ETag: etag}, 0)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if !got.NotModified || len(got.Posts) != 0 {
+			t.Errorf("Fetch() = %+v, want not modified", got)
+		}
+	})
+	t.Run("If-Modified-Since", func(t *testing.T) {
+		got, err := Fetch(ctx, client, ts.URL, Validators{LastModified: lastModified}, 0)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if !got.NotModified {
+			t.Errorf("Fetch() = %+v, want not modified", got)
+		}
+	})
+}
+
+func TestFetch_Compression(t *testing.T) {
+	var gzipped, brotlied bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(rdfXML))
+	gz.Close()
+	br := brotli.NewWriter(&brotlied)
+	br.Write([]byte(rdfXML))
+	br.Close()
+
+	for _, tt := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{encoding: "gzip", body: gzipped.Bytes()},
+		{encoding: "br", body: brotlied.Bytes()},
+		{encoding: "", body: []byte(rdfXML)},
+	} {
+		t.Run("Content-Encoding "+tt.encoding, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != "br, gzip" {
+					t.Errorf("Accept-Encoding = %q, want br, gzip", got)
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer ts.Close()
+
+			got, err := Fetch(context.Background(), ts.Client(), ts.URL, Validators{}, 0)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if len(got.Posts) != 2 || got.Posts[0].Title != "RDF Title #1" {
+				t.Errorf("Fetch() = %+v, want the 2 RDF posts", got.Posts)
+			}
+		})
+	}
+}
+
+func TestFetch_MaxBodySize(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(rdfXML))
+	gz.Close()
+
+	size := int64(len(rdfXML))
+	tests := []struct {
+		name    string
+		maxBody int64
+		gzip    bool
+		chunked bool
+		wantErr bool
+	}{
+		{name: "fits exactly", maxBody: size},
+		{name: "Content-Length over the limit", maxBody: size - 1, wantErr: true},
+		{name: "streamed over the limit", maxBody: size / 2, chunked: true, wantErr: true},
+		{name: "decompressed over the limit", maxBody: int64(gzipped.Len()), gzip: true, wantErr: true},
+		{name: "default limit", maxBody: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case tt.gzip:
+					w.Header().Set("Content-Encoding", "gzip")
+					w.Write(gzipped.Bytes())
+				case tt.chunked:
+					w.Write([]byte(rdfXML[:size/2]))
+					w.(http.Flusher).Flush()
+					w.Write([]byte(rdfXML[size/2:]))
+				default:
+					w.Write([]byte(rdfXML))
+				}
+			}))
+			defer ts.Close()
+
+			got, err := Fetch(context.Background(), ts.Client(), ts.URL, Validators{}, tt.maxBody)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBodyTooLarge) || !strings.Contains(err.Error(), fmt.Sprintf("more than %d bytes", tt.maxBody)) {
+					t.Errorf("Fetch() error = %v, want ErrBodyTooLarge", err)
+				}
+				return
+			}
+			if err != nil || len(got.Posts) != 2 {
+				t.Errorf("Fetch() = %d posts, %v, want 2 posts", len(got.Posts), err)
+			}
+		})
+	}
+}
+
+func TestFetch_PollingHints(t *testing.T) {
+	const hintsXML = `<rss version="2.0">
+    <channel>
+        <title>Hints</title>
+        <ttl>60</ttl>
+        <skipHours><hour>0</hour><hour>1</hour><hour>25</hour></skipHours>
+        <skipDays><day>Saturday</day><day>Sunday</day></skipDays>
+    </channel>
+    </rss>`
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	ctx := context.Background()
+
+	t.Run("TTL and skip lists", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(hintsXML))
+		}))
+		defer ts.Close()
+
+		got, err := Fetch(ctx, client, ts.URL, Validators{}, 0)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		if got.TTL != time.Hour {
+			t.Errorf("TTL = %v, want 1h", got.TTL)
+		}
+		if !reflect.DeepEqual(got.SkipHours, []int{0, 1}) {
+			t.Errorf("SkipHours = %v, want [0 1]", got.SkipHours)
+		}
+		if !reflect.DeepEqual(got.SkipDays, []time.Weekday{time.Saturday, time.Sunday}) {
+			t.Errorf("SkipDays = %v, want [Saturday Sunday]", got.SkipDays)
+		}
+	})
+	t.Run("Retry-After", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		got, err := Fetch(ctx, client, ts.URL, Validators{}, 0)
+		if err == nil {
+			t.Fatalf("Fetch() error = nil, want error")
+		}
+		if got.RetryAfter != 2*time.Minute {
+			t.Errorf("RetryAfter = %v, want 2m", got.RetryAfter)
+		}
+	})
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"Fri, 01 Mar 2024 11:00:00 GMT", time.Hour},
+		{"Fri, 01 Mar 2024 09:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFetch_Channel(t *testing.T) {
+	tests := []struct {
+		name      string
+		doc       string
+		wantTitle string
+		wantLink  string
+	}{
+		{"RSS", `<rss version="2.0"><channel><title> Demo </title><link>https://example.ru/</link></channel></rss>`, "Demo", "https://example.ru/"},
+		{"Atom", atomXML, "Demo Atom", "https://example.com/"},
+		{"RDF", rdfXML, "Demo RDF", "https://example.org/"},
+		{"JSON Feed", jsonFeedDoc, "Demo JSON Feed", "https://example.net/"},
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	ctx := context.Background()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.doc))
+			}))
+			defer ts.Close()
+
+			got, err := Fetch(ctx, client, ts.URL, Validators{}, 0)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if got.Title != tt.wantTitle || got.Link != tt.wantLink {
+				t.Errorf("Fetch() channel = %q %q, want %q %q", got.Title, got.Link, tt.wantTitle, tt.wantLink)
+			}
+		})
+	}
+}
+
+func Test_decodeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "RSS categories",
+			doc: `<rss version="2.0"><channel><item>
+				<title>Item</title><link>https://example.com/1</link>
+				<category>Go</category><category>  Open   Source </category><category>go</category><category></category>
+			</item></channel></rss>`,
+			want: []string{"go", "open source"},
+		},
+		{
+			name: "Atom categories",
+			doc: `<feed xmlns="http://www.w3.org/2005/Atom"><entry>
+				<title>Entry</title><link href="https://example.com/1"/>
+				<category term="golang" label="Go"/><category term="releases"/>
+			</entry></feed>`,
+			want: []string{"go", "releases"},
+		},
+		{
+			name: "RDF subjects",
+			doc: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns="http://purl.org/rss/1.0/">
+				<item rdf:about="https://example.org/1"><title>Item</title><link>https://example.org/1</link><dc:subject>Science</dc:subject></item>
+			</rdf:RDF>`,
+			want: []string{"science"},
+		},
+		{
+			name: "JSON Feed tags",
+			doc:  `{"version": "https://jsonfeed.org/version/1.1", "items": [{"url": "https://example.net/1", "tags": ["#Podcast", "Tech"]}]}`,
+			want: []string{"podcast", "tech"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := decode(strings.NewReader(tt.doc), "")
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if len(res.Posts) != 1 {
+				t.Fatalf("decode() = %d posts, want 1", len(res.Posts))
+			}
+			if got := res.Posts[0].Tags; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeLinks(t *testing.T) {
+	tests := []struct {
+		name         string
+		doc          string
+		wantChannel  string
+		wantItemLink string
+	}{
+		{
+			name: "RSS atom:link after link",
+			doc: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+				<title>Blog</title><link>https://example.com/</link>
+				<atom:link href="https://example.com/feed" rel="self" type="application/rss+xml"/>
+				<item><title>Item</title><link>https://example.com/1</link><atom:link href="https://example.com/1/amp" rel="amphtml"/></item>
+			</channel></rss>`,
+			wantChannel:  "https://example.com/",
+			wantItemLink: "https://example.com/1",
+		},
+		{
+			name: "RSS atom:link before link, undeclared prefix",
+			doc: `<rss version="2.0"><channel>
+				<atom:link href="https://example.com/feed" rel="self"/><title>Blog</title><link>https://example.com/</link>
+				<item><title>Item</title><atom:link href="https://example.com/1/amp"/><link>https://example.com/1</link></item>
+			</channel></rss>`,
+			wantChannel:  "https://example.com/",
+			wantItemLink: "https://example.com/1",
+		},
+		{
+			name: "RDF atom:link",
+			doc: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:atom="http://www.w3.org/2005/Atom" xmlns="http://purl.org/rss/1.0/">
+				<channel rdf:about="https://example.org/"><title>Site</title><link>https://example.org/</link><atom:link href="https://example.org/rdf" rel="self"/></channel>
+				<item rdf:about="https://example.org/1"><title>Item</title><link>https://example.org/1</link></item>
+			</rdf:RDF>`,
+			wantChannel:  "https://example.org/",
+			wantItemLink: "https://example.org/1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := decode(strings.NewReader(tt.doc), "")
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if res.Link != tt.wantChannel {
+				t.Errorf("Link = %q, want %q", res.Link, tt.wantChannel)
+			}
+			if len(res.Posts) != 1 || res.Posts[0].Link != tt.wantItemLink {
+				t.Fatalf("Posts = %+v, want one post linking to %q", res.Posts, tt.wantItemLink)
+			}
+		})
+	}
+}
+
+func Test_decodeAuthor(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		wantAuthor string
+		wantGUID   string
+	}{
+		{
+			name: "RSS author with email",
+			doc: `<rss version="2.0"><channel><item>
+				<title>Item</title><link>https://example.com/1</link>
+				<guid isPermaLink="false"> post-1 </guid><author>editor@example.com (Jane  Doe)</author>
+			</item></channel></rss>`,
+			wantAuthor: "Jane Doe",
+			wantGUID:   "post-1",
+		},
+		{
+			name: "RSS dc:creator over author",
+			doc: `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel><item>
+				<title>Item</title><link>https://example.com/1</link>
+				<author>editor@example.com</author><dc:creator>John Doe</dc:creator>
+			</item></channel></rss>`,
+			wantAuthor: "John Doe",
+		},
+		{
+			name:       "JSON Feed 1.0 author",
+			doc:        `{"version": "https://jsonfeed.org/version/1", "items": [{"id": "42", "url": "https://example.net/1", "author": {"name": "Ann"}}]}`,
+			wantAuthor: "Ann",
+			wantGUID:   "42",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := decode(strings.NewReader(tt.doc), "")
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if len(res.Posts) != 1 {
+				t.Fatalf("decode() = %d posts, want 1", len(res.Posts))
+			}
+			if got := res.Posts[0]; got.Author != tt.wantAuthor || got.GUID != tt.wantGUID {
+				t.Errorf("Author, GUID = %q, %q, want %q, %q", got.Author, got.GUID, tt.wantAuthor, tt.wantGUID)
+			}
+		})
+	}
+}
+
+// cyrillicRSS is an RSS document in Russian declaring the given encoding in its prolog.
+func cyrillicRSS(encoding string) string {
+	prolog := `<?xml version="1.0"?>`
+	if encoding != "" {
+		prolog = `<?xml version="1.0" encoding="` + encoding + `"?>`
+	}
+	return prolog + `<rss version="2.0"><channel><title>Новости</title><item>
+		<title>Заголовок новости</title><link>https://example.ru/1</link>
+		<description>Текст новости</description><category>Политика</category>
+	</item></channel></rss>`
+}
+
+func TestParse_Charsets(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        func() ([]byte, error)
+		contentType string
+	}{
+		{
+			name: "windows-1251 from the prolog",
+			body: func() ([]byte, error) {
+				return charmap.Windows1251.NewEncoder().Bytes([]byte(cyrillicRSS("windows-1251")))
+			},
+			contentType: "application/rss+xml",
+		},
+		{
+			name: "KOI8-R from the Content-Type",
+			body: func() ([]byte, error) {
+				return charmap.KOI8R.NewEncoder().Bytes([]byte(cyrillicRSS("")))
+			},
+			contentType: "text/xml; charset=KOI8-R",
+		},
+		{
+			name: "Content-Type over the prolog",
+			body: func() ([]byte, error) {
+				return charmap.KOI8R.NewEncoder().Bytes([]byte(cyrillicRSS("windows-1251")))
+			},
+			contentType: "application/xml; charset=koi8-r",
+		},
+		{
+			name: "UTF-16 with a byte order mark",
+			body: func() ([]byte, error) {
+				return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(cyrillicRSS("UTF-16")))
+			},
+			contentType: "text/xml; charset=windows-1251",
+		},
+		{
+			name: "UTF-8 with a byte order mark",
+			body: func() ([]byte, error) {
+				return append([]byte("\ufeff"), cyrillicRSS("utf-8")...), nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := tt.body()
+			if err != nil {
+				t.Fatalf("failed to encode fixture: %v", err)
+			}
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Write(body)
+			}))
+			defer ts.Close()
+
+			res, err := Fetch(context.Background(), ts.Client(), ts.URL, Validators{}, 0)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if res.Title != "Новости" || len(res.Posts) != 1 {
+				t.Fatalf("Fetch() = %q with %d posts, want Новости with 1 post", res.Title, len(res.Posts))
+			}
+			p := res.Posts[0]
+			if p.Title != "Заголовок новости" || p.Content != "Текст новости" || !reflect.DeepEqual(p.Tags, []string{"политика"}) {
+				t.Errorf("post = %q %q %q, want the text in UTF-8", p.Title, p.Content, p.Tags)
+			}
+		})
+	}
+
+	t.Run("unsupported charset", func(t *testing.T) {
+		_, err := decode(strings.NewReader(cyrillicRSS("x-unknown")), "")
+		if err == nil || !strings.Contains(err.Error(), "unsupported charset") {
+			t.Errorf("decode() error = %v, want unsupported charset", err)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package rss
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedAttrs lists the elements kept by sanitizeHTML and the attributes kept on each.
+// Elements not listed are unwrapped: their markup is dropped but their content is kept.
+var allowedAttrs = map[atom.Atom][]string{
+	atom.P:          nil,
+	atom.Br:         nil,
+	atom.Hr:         nil,
+	atom.Div:        nil,
+	atom.Span:       nil,
+	atom.B:          nil,
+	atom.Strong:     nil,
+	atom.I:          nil,
+	atom.Em:         nil,
+	atom.U:          nil,
+	atom.S:          nil,
+	atom.Sub:        nil,
+	atom.Sup:        nil,
+	atom.Code:       nil,
+	atom.Pre:        nil,
+	atom.Blockquote: nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Ul:         nil,
+	atom.Ol:         nil,
+	atom.Li:         nil,
+	atom.Figure:     nil,
+	atom.Figcaption: nil,
+	atom.Table:      nil,
+	atom.Thead:      nil,
+	atom.Tbody:      nil,
+	atom.Tr:         nil,
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Td:         {"colspan", "rowspan"},
+	atom.A:          {"href", "title"},
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+}
+
+// droppedElements are removed together with their content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Base:     true,
+}
+
+// voidElements have no closing tag.
+var voidElements = map[atom.Atom]bool{
+	atom.Br:  true,
+	atom.Hr:  true,
+	atom.Img: true,
+}
+
+// sanitizeHTML keeps only allowlisted elements and attributes of the feed markup.
+// Scripts, frames and other active content are removed with their content, event handlers
+// and styles are dropped with every other unlisted attribute, and links and images are
+// resolved against base; URLs with schemes other than http, https (and mailto for links)
+// are removed.
+func sanitizeHTML(raw, base string) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(raw), body)
+	if err != nil {
+		return ""
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil || !baseURL.IsAbs() {
+		baseURL = nil
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		renderSafe(&b, n, baseURL)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// renderSafe writes the node and its children, keeping only allowlisted markup.
+func renderSafe(b *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if droppedElements[n.DataAtom] {
+		return
+	}
+
+	attrs, allowed := allowedAttrs[n.DataAtom]
+	if !allowed {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			renderSafe(b, c, base)
+		}
+		return
+	}
+
+	b.WriteByte('<')
+	b.WriteString(n.Data)
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !slices.Contains(attrs, a.Key) {
+			continue
+		}
+		val := a.Val
+		if a.Key == "href" || a.Key == "src" {
+			var ok bool
+			val, ok = safeURL(val, base, a.Key == "href")
+			if !ok {
+				continue
+			}
+		}
+		b.WriteByte(' ')
+		b.WriteString(a.Key)
+		b.WriteString(`="`)
+		b.WriteString(html.EscapeString(val))
+		b.WriteByte('"')
+	}
+	if n.DataAtom == atom.A {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteByte('>')
+
+	if voidElements[n.DataAtom] {
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		renderSafe(b, c, base)
+	}
+	b.WriteString("</")
+	b.WriteString(n.Data)
+	b.WriteByte('>')
+}
+
+// safeURL resolves a link or image URL against base and reports whether its scheme is allowed.
+// Relative URLs are dropped when there is no base to resolve them against.
+func safeURL(raw string, base *url.URL, link bool) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.String(), true
+	case "mailto":
+		return u.String(), link
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,61 @@
+package rss
+
+import "testing"
+
+func Test_sanitizeHTML(t *testing.T) {
+	const base = "https://example.com/news/42"
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "Plain text",
+			raw:  "Fish & chips < 5€",
+			want: "Fish &amp; chips &lt; 5€",
+		},
+		{
+			name: "Allowed markup",
+			raw:  "<p>One <b>two</b></p><ul><li>three</li></ul>",
+			want: "<p>One <b>two</b></p><ul><li>three</li></ul>",
+		},
+		{
+			name: "Scripts and frames removed with content",
+			raw:  `<p>Text</p><script>alert(1)</script><iframe src="https://evil.example"></iframe><style>p{}</style>`,
+			want: "<p>Text</p>",
+		},
+		{
+			name: "Event handlers and styles dropped",
+			raw:  `<p onclick="steal()" style="color:red" class="x">Text</p><img src="/a.png" onerror="steal()" alt="A">`,
+			want: `<p>Text</p><img src="https://example.com/a.png" alt="A">`,
+		},
+		{
+			name: "Relative links resolved",
+			raw:  `<a href="../about?x=1">About</a> <a href="#top">Top</a>`,
+			want: `<a href="https://example.com/about?x=1" rel="nofollow noopener noreferrer">About</a> <a href="https://example.com/news/42#top" rel="nofollow noopener noreferrer">Top</a>`,
+		},
+		{
+			name: "Dangerous schemes removed",
+			raw:  `<a href="javascript:alert(1)">x</a><img src="data:image/png;base64,AAAA"><a href=" JaVaScRiPt:alert(1)">y</a><a href="mailto:editor@example.com">z</a>`,
+			want: `<a rel="nofollow noopener noreferrer">x</a><img><a rel="nofollow noopener noreferrer">y</a><a href="mailto:editor@example.com" rel="nofollow noopener noreferrer">z</a>`,
+		},
+		{
+			name: "Unknown elements unwrapped",
+			raw:  `<section><font color="red">Text</font></section>`,
+			want: "Text",
+		},
+		{
+			name: "Attribute values escaped",
+			raw:  `<a href="https://example.com/?a=1&b=2" title='"quoted"'>x</a>`,
+			want: `<a href="https://example.com/?a=1&amp;b=2" title="&#34;quoted&#34;" rel="nofollow noopener noreferrer">x</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeHTML(tt.raw, base); got != tt.want {
+				t.Errorf("sanitizeHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"fmt"
+	"news/pkg/dedup"
+	"news/pkg/storage"
+)
+
+// clusterOf selects the story cluster for a post being inserted with parameters
+// $3 (pub_time), $4 (link) and $8 (simhash): the cluster of the oldest post published
+// within a day of it whose fingerprint is at most dedup.MaxDistance bits away.
+// It yields NULL when the post starts a new story or has no fingerprint.
+var clusterOf = fmt.Sprintf(`
+		SELECT
+			COALESCE(c.cluster_id, c.id)
+		FROM
+			posts c
+		WHERE
+			$8::bigint <> 0
+			AND c.simhash <> 0
+			AND c.link <> $4
+			AND c.pub_time BETWEEN $3::timestamp - INTERVAL '1 day' AND $3::timestamp + INTERVAL '1 day'
+			AND bit_count((c.simhash # $8::bigint)::bit(64)) <= %d
+		ORDER BY
+			c.id
+		LIMIT 1`, dedup.MaxDistance)
+
+// fingerprint returns the SimHash of the post's title and content as stored in the simhash column.
+func fingerprint(p storage.Post) int64 {
+	return int64(dedup.SimHash(p.Title + " " + p.Content))
+}
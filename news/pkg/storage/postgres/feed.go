@@ -0,0 +1,305 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"news/pkg/storage"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// feedColumns lists the feeds columns read by scanFeed, in order.
+const feedColumns = `
+		id,
+		url,
+		title,
+		category,
+		interval_minutes,
+		enabled,
+		full_text,
+		max_body_size,
+		last_attempt,
+		last_success,
+		last_error,
+		failures,
+		items_ingested`
+
+// FeedState returns the cache validators stored for the feed URL.
+// A feed that has never been polled yields an empty state.
+func (ps *PostgresStorage) FeedState(url string) (storage.FeedState, error) {
+	s := storage.FeedState{URL: url}
+	err := ps.db.QueryRow(context.Background(), `
+	SELECT
+		etag,
+		last_modified
+	FROM
+		feeds
+	WHERE
+		url = $1;
+	`,
+		url,
+	).Scan(
+		&s.ETag,
+		&s.LastModified,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to execute query for FeedState: %w", err)
+	}
+
+	return s, nil
+}
+
+// SaveFeedState stores the cache validators of the feed.
+// Feeds deleted in the meantime are left alone.
+func (ps *PostgresStorage) SaveFeedState(s storage.FeedState) error {
+	_, err := ps.db.Exec(context.Background(), `
+	UPDATE feeds SET
+		etag = $2,
+		last_modified = $3
+	WHERE
+		url = $1;
+	`,
+		s.URL, s.ETag, s.LastModified,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save feed state: %w", err)
+	}
+	return nil
+}
+
+// RecordFetch records the outcome of a feed poll: a success resets the failure counter
+// and adds items to the ingested total, an error is remembered and counted.
+func (ps *PostgresStorage) RecordFetch(url string, items int, fetchErr error) error {
+	now := time.Now().UTC()
+	var err error
+	if fetchErr == nil {
+		_, err = ps.db.Exec(context.Background(), `
+		UPDATE feeds SET
+			last_attempt = $2,
+			last_success = $2,
+			last_error = '',
+			failures = 0,
+			items_ingested = items_ingested + $3
+		WHERE
+			url = $1;
+		`,
+			url, now, items,
+		)
+	} else {
+		_, err = ps.db.Exec(context.Background(), `
+		UPDATE feeds SET
+			last_attempt = $2,
+			last_error = $3,
+			failures = failures + 1
+		WHERE
+			url = $1;
+		`,
+			url, now, fetchErr.Error(),
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record feed fetch: %w", err)
+	}
+	return nil
+}
+
+// Feeds returns all feeds with their settings and health, ordered by ID.
+func (ps *PostgresStorage) Feeds() ([]storage.Feed, error) {
+	rows, err := ps.db.Query(context.Background(), `
+	SELECT`+feedColumns+`
+	FROM
+		feeds
+	ORDER BY
+		id;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for Feeds: %w", err)
+	}
+	defer rows.Close()
+
+	var feeds []storage.Feed
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return feeds, nil
+}
+
+// Feed returns a feed with its settings and health by ID.
+func (ps *PostgresStorage) Feed(id int) (storage.Feed, error) {
+	row := ps.db.QueryRow(context.Background(), `
+	SELECT`+feedColumns+`
+	FROM
+		feeds
+	WHERE
+		id = $1;
+	`,
+		id,
+	)
+
+	f, err := scanFeed(row)
+	if err != nil {
+		return f, fmt.Errorf("failed to execute query for Feed: %w", err)
+	}
+	return f, nil
+}
+
+// AddFeed adds a new feed to the database.
+func (ps *PostgresStorage) AddFeed(f storage.Feed) (storage.Feed, error) {
+	row := ps.db.QueryRow(context.Background(), `
+	INSERT INTO feeds (url, title, category, interval_minutes, enabled, full_text, max_body_size)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING`+feedColumns+`;
+	`,
+		f.URL, f.Title, f.Category, f.Interval, f.Enabled, f.FullText, f.MaxBodySize,
+	)
+
+	feed, err := scanFeed(row)
+	if err != nil {
+		return feed, fmt.Errorf("failed to create feed: %w", err)
+	}
+	return feed, nil
+}
+
+// ImportFeeds merges a feed list into the feeds table in a single pgx batch: unknown URLs are
+// added with their settings, known feeds only get the title and category they lack.
+func (ps *PostgresStorage) ImportFeeds(feeds []storage.Feed) (storage.UpsertResult, error) {
+	var res storage.UpsertResult
+	if len(feeds) == 0 {
+		return res, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, f := range feeds {
+		batch.Queue(`
+		INSERT INTO feeds (url, title, category, interval_minutes, enabled, full_text, max_body_size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (url) DO UPDATE SET
+			title = COALESCE(NULLIF(feeds.title, ''), EXCLUDED.title),
+			category = COALESCE(NULLIF(feeds.category, ''), EXCLUDED.category)
+		WHERE
+			(feeds.title = '' AND EXCLUDED.title <> '')
+			OR (feeds.category = '' AND EXCLUDED.category <> '')
+		RETURNING
+			(xmax = 0) AS inserted;
+		`,
+			f.URL, f.Title, f.Category, f.Interval, f.Enabled, f.FullText, f.MaxBodySize,
+		)
+	}
+
+	br := ps.db.SendBatch(context.Background(), batch)
+	defer br.Close()
+
+	for _, f := range feeds {
+		var inserted bool
+		err := br.QueryRow().Scan(&inserted)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			res.Unchanged++
+		case err != nil:
+			return res, fmt.Errorf("failed to import feed %s: %w", f.URL, err)
+		case inserted:
+			res.Inserted++
+		default:
+			res.Updated++
+		}
+	}
+
+	err := br.Close()
+	if err != nil {
+		return res, fmt.Errorf("failed to close batch: %w", err)
+	}
+
+	return res, nil
+}
+
+// UpdateFeed saves the title, category, interval, enabled and full text flags and the body
+// size limit of an existing feed.
+func (ps *PostgresStorage) UpdateFeed(f storage.Feed) (storage.Feed, error) {
+	row := ps.db.QueryRow(context.Background(), `
+	UPDATE feeds SET
+		title = $2,
+		category = $3,
+		interval_minutes = $4,
+		enabled = $5,
+		full_text = $6,
+		max_body_size = $7
+	WHERE
+		id = $1
+	RETURNING`+feedColumns+`;
+	`,
+		f.ID, f.Title, f.Category, f.Interval, f.Enabled, f.FullText, f.MaxBodySize,
+	)
+
+	feed, err := scanFeed(row)
+	if err != nil {
+		return feed, fmt.Errorf("failed to update feed: %w", err)
+	}
+	return feed, nil
+}
+
+// DeleteFeed removes the feed by ID. It returns pgx.ErrNoRows if there is no such feed.
+func (ps *PostgresStorage) DeleteFeed(id int) error {
+	tag, err := ps.db.Exec(context.Background(), `DELETE FROM feeds WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete feed: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete feed: %w", pgx.ErrNoRows)
+	}
+	return nil
+}
+
+// scanFeed reads a feeds row, mapping NULL timestamps to the zero time.
+func scanFeed(row pgx.Row) (storage.Feed, error) {
+	var f storage.Feed
+	var lastAttempt, lastSuccess *time.Time
+	err := row.Scan(
+		&f.ID,
+		&f.URL,
+		&f.Title,
+		&f.Category,
+		&f.Interval,
+		&f.Enabled,
+		&f.FullText,
+		&f.MaxBodySize,
+		&lastAttempt,
+		&lastSuccess,
+		&f.LastError,
+		&f.Failures,
+		&f.ItemsIngested,
+	)
+	if err != nil {
+		return f, fmt.Errorf("failed to scan feed row: %w", err)
+	}
+
+	if lastAttempt != nil {
+		f.LastAttempt = *lastAttempt
+	}
+	if lastSuccess != nil {
+		f.LastSuccess = *lastSuccess
+	}
+	return f, nil
+}
+
+// ClearFeeds clears the table.
+func (ps *PostgresStorage) ClearFeeds() error {
+	_, err := ps.db.Exec(context.Background(), `DELETE FROM feeds;`)
+	if err != nil {
+		return fmt.Errorf("failed to clean up feeds table: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"news/pkg/storage"
+)
+
+// saveMedia stores the media of the post with link $1, given as parallel arrays of URLs ($2),
+// types ($3), lengths ($4), widths ($5) and heights ($6). Media already known for the post
+// are updated in place.
+const saveMedia = `
+	INSERT INTO media (post_id, url, type, length, width, height)
+	SELECT
+		p.id, m.url, m.type, m.length, m.width, m.height
+	FROM
+		posts p,
+		unnest($2::text[], $3::text[], $4::bigint[], $5::int[], $6::int[]) AS m(url, type, length, width, height)
+	WHERE
+		p.link = $1
+	ON CONFLICT (post_id, url) DO UPDATE SET
+		type = EXCLUDED.type,
+		length = EXCLUDED.length,
+		width = EXCLUDED.width,
+		height = EXCLUDED.height;`
+
+// mediaArgs returns the saveMedia arguments for the post.
+func mediaArgs(p storage.Post) []any {
+	n := len(p.Media)
+	urls, types := make([]string, n), make([]string, n)
+	lengths := make([]int64, n)
+	widths, heights := make([]int32, n), make([]int32, n)
+	for i, m := range p.Media {
+		urls[i], types[i] = m.URL, m.Type
+		lengths[i] = m.Length
+		widths[i], heights[i] = int32(m.Width), int32(m.Height)
+	}
+	return []any{p.Link, urls, types, lengths, widths, heights}
+}
+
+// mediaByPost returns the media of the posts by post ID, in feed order.
+func (ps *PostgresStorage) mediaByPost(ids []int) (map[int][]storage.Media, error) {
+	media := make(map[int][]storage.Media)
+	if len(ids) == 0 {
+		return media, nil
+	}
+
+	rows, err := ps.db.Query(context.Background(), `
+	SELECT
+		post_id,
+		url,
+		type,
+		length,
+		width,
+		height
+	FROM
+		media
+	WHERE
+		post_id = ANY($1)
+	ORDER BY
+		post_id, id;
+	`,
+		ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for media: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var m storage.Media
+		err := rows.Scan(&postID, &m.URL, &m.Type, &m.Length, &m.Width, &m.Height)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan media row: %w", err)
+		}
+		media[postID] = append(media[postID], m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return media, nil
+}
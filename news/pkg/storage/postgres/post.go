@@ -2,199 +2,484 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"news/pkg/storage"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v4"
 )
 
-// Post retrieves a post by its ID from the database.
-func (ps *PostgresStorage) Post(newsID int) (storage.Post, error) {
-	var p storage.Post
-	err := ps.db.QueryRow(context.Background(), `
-	SELECT
+// postColumns lists the posts columns read by scanPost, in order.
+// The first post of a story has no cluster_id and is the cluster itself.
+const postColumns = `
 		id,
 		title,
 		content,
+		content_html,
+		full_content,
+		author,
+		guid,
 		pub_time,
-		link
-	FROM 
+		date_estimated,
+		link,
+		feed_id,
+		source_title,
+		source_link,
+		COALESCE(cluster_id, id)`
+
+// Post retrieves a post by its ID from the database.
+func (ps *PostgresStorage) Post(newsID int) (storage.Post, error) {
+	row := ps.db.QueryRow(context.Background(), `
+	SELECT`+postColumns+`
+	FROM
 		posts
 	WHERE
 		id = $1;
 	`,
 		newsID,
-	).Scan(
-		&p.ID,
-		&p.Title,
-		&p.Content,
-		&p.PubTime,
-		&p.Link,
 	)
+
+	p, err := scanPost(row)
 	if err != nil {
 		return p, fmt.Errorf("failed to execute query for Post: %w", err)
 	}
 
+	err = ps.attachRelations(&p)
+	if err != nil {
+		return p, err
+	}
+
 	return p, nil
 }
 
-// Posts returns a list of posts ordered by pub_time.
-func (ps *PostgresStorage) Posts(limit int) ([]storage.Post, error) {
+// PostsByCursor returns up to limit posts of the filtered list next to the cursor using
+// keyset pagination over (pub_time, id), so pages stay stable while new posts arrive.
+// A nil cursor returns the newest posts.
+func (ps *PostgresStorage) PostsByCursor(c *storage.Cursor, limit int, f storage.PostFilter) ([]storage.Post, storage.CursorPage, error) {
+	var page storage.CursorPage
+
+	// The newest posts come first; a Before cursor walks towards newer posts in ascending
+	// order and the rows are reversed afterwards. One extra row tells whether there is more.
+	var b queryBuilder
+	b.filter(f)
+	order := "DESC"
+	if c != nil {
+		cmp := "<"
+		if c.Before {
+			cmp, order = ">", "ASC"
+		}
+		b.where("(pub_time, id) " + cmp + " (" + b.arg(c.PubTime) + ", " + b.arg(c.ID) + ")")
+	}
+
 	rows, err := ps.db.Query(context.Background(), `
-	SELECT 
-		id, 
-		title, 
-		content,
-		pub_time,
-		link
-	FROM 
+	SELECT`+postColumns+`
+	FROM
 		posts
-	ORDER BY 
-		pub_time DESC
-	LIMIT $1;
+	WHERE
+		`+b.conditions()+`
+	ORDER BY
+		pub_time `+order+`, id `+order+`
+	LIMIT `+b.arg(limit+1)+`;
 	`,
-		limit)
+		b.args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query for Posts: %w", err)
+		return nil, page, fmt.Errorf("failed to execute query for PostsByCursor: %w", err)
 	}
-	defer rows.Close()
 
-	var posts []storage.Post
-	for rows.Next() {
-		var p storage.Post
-		err = rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Content,
-			&p.PubTime,
-			&p.Link,
-		)
+	posts, err := collectPosts(rows)
+	if err != nil {
+		return nil, page, err
+	}
+
+	err = ps.attachRelations(pointers(posts)...)
+	if err != nil {
+		return nil, page, err
+	}
+
+	more := len(posts) > limit
+	if more {
+		posts = posts[:limit]
+	}
+
+	if c != nil && c.Before {
+		slices.Reverse(posts)
+	}
+
+	if len(posts) == 0 {
+		return posts, page, nil
+	}
+
+	first, last := posts[0], posts[len(posts)-1]
+	hasNext := more || (c != nil && c.Before)
+	hasPrev := c != nil && (!c.Before || more)
+	if hasNext {
+		page.Next = &storage.Cursor{PubTime: last.PubTime, ID: last.ID}
+	}
+	if hasPrev {
+		page.Prev = &storage.Cursor{PubTime: first.PubTime, ID: first.ID, Before: true}
+	}
+
+	return posts, page, nil
+}
+
+// ClusterPosts returns the posts of a story cluster, oldest first.
+func (ps *PostgresStorage) ClusterPosts(clusterID int) ([]storage.Post, error) {
+	rows, err := ps.db.Query(context.Background(), `
+	SELECT`+postColumns+`
+	FROM
+		posts
+	WHERE
+		id = $1 OR cluster_id = $1
+	ORDER BY
+		pub_time, id;
+	`,
+		clusterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for ClusterPosts: %w", err)
+	}
+
+	posts, err := collectPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ps.attachRelations(pointers(posts)...)
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// AddPost adds a new post with its media and tags to the database, joining the story cluster of a
+// near-duplicate post.
+func (ps *PostgresStorage) AddPost(p storage.Post) (storage.Post, error) {
+	row := ps.db.QueryRow(context.Background(), `
+	INSERT INTO posts (title, content, pub_time, link, feed_id, source_title, source_link, simhash, cluster_id, full_content, content_html, author, guid, date_estimated)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7, $8, (`+clusterOf+`), $9, $10, $11, $12, $13)
+	RETURNING`+postColumns+`;
+	`,
+		p.Title, p.Content, p.PubTime, p.Link, p.Source.FeedID, p.Source.Title, p.Source.Link, fingerprint(p), p.FullContent, p.ContentHTML, p.Author, p.GUID, p.DateEstimated,
+	)
+
+	post, err := scanPost(row)
+	if err != nil {
+		return post, fmt.Errorf("failed to create post: %w", err)
+	}
+
+	post.Media, post.Tags = p.Media, p.Tags
+	if len(post.Media) > 0 {
+		_, err = ps.db.Exec(context.Background(), saveMedia, mediaArgs(post)...)
+		if err != nil {
+			return post, fmt.Errorf("failed to save media: %w", err)
+		}
+	}
+	if len(post.Tags) > 0 {
+		_, err = ps.db.Exec(context.Background(), saveTags, post.Link, post.Tags)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan post row: %w", err)
+			return post, fmt.Errorf("failed to save tags: %w", err)
 		}
+	}
 
-		posts = append(posts, p)
+	return post, nil
+}
+
+// relinkByGUID moves a post of the feed with the same GUID to the new link, so that the upsert
+// that follows updates it instead of inserting a duplicate. A link already taken is left alone.
+const relinkByGUID = `
+		UPDATE posts
+		SET
+			link = $3
+		WHERE
+			feed_id = $1
+			AND guid = $2
+			AND link <> $3
+			AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.link = $3);
+		`
+
+// maxTitleLength is the longest title the posts table accepts, in characters.
+const maxTitleLength = 255
+
+// upsertOutcome tells what UpsertPosts did with a post.
+type upsertOutcome int
+
+const (
+	postUnchanged upsertOutcome = iota
+	postInserted
+	postUpdated
+)
+
+// UpsertPosts inserts new posts and updates the title, author and content (text and HTML) of posts
+// that already exist, in a single transaction with one pgx batch per post.
+// A post exists if it has the same link or, when both carry a GUID, the same feed and GUID; in the
+// latter case its link is changed to the new one. A GUID already taken by another post of the
+// feed is not given to a post stored without one.
+// New posts join the story cluster of a near-duplicate post, see clusterOf. Media and tags are
+// added or updated with their post; those dropped from the feed are kept.
+// An estimated publication date stays as first stored until the feed gives a real one.
+// Extracted full content is only replaced by a non-empty one, so polls that skip extraction
+// keep it. Posts with an existing link and nothing new are left unchanged.
+// Titles longer than the table allows are shortened. Every post is stored behind a savepoint,
+// so a post the database rejects is rolled back and skipped without losing the others; the
+// returned error then wraps storage.ErrSkipped.
+func (ps *PostgresStorage) UpsertPosts(posts []storage.Post) (storage.UpsertResult, error) {
+	var res storage.UpsertResult
+	if len(posts) == 0 {
+		return res, nil
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+	ctx := context.Background()
+	tx, err := ps.db.Begin(ctx)
+	if err != nil {
+		return res, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	return posts, nil
+	var skipped []error
+	for _, p := range posts {
+		p.Title = truncate(p.Title, maxTitleLength)
+
+		outcome, err := upsertOne(ctx, tx, p)
+		if err != nil {
+			_, rbErr := tx.Exec(ctx, `ROLLBACK TO SAVEPOINT post;`)
+			if rbErr != nil {
+				return storage.UpsertResult{}, fmt.Errorf("failed to skip post %s: %w", p.Link, errors.Join(err, rbErr))
+			}
+			res.Skipped++
+			skipped = append(skipped, err)
+			continue
+		}
+
+		switch outcome {
+		case postInserted:
+			res.Inserted++
+		case postUpdated:
+			res.Updated++
+		default:
+			res.Unchanged++
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return storage.UpsertResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if len(skipped) > 0 {
+		return res, fmt.Errorf("%w: %w", storage.ErrSkipped, errors.Join(skipped...))
+	}
+	return res, nil
 }
 
-// GetPostsPaginated returns a paginated list of posts and pagination info.
-func (ps *PostgresStorage) GetPostsPaginated(page, perPage int) ([]storage.Post, storage.Pagination, error) {
-	var totalCount int
-	err := ps.db.QueryRow(context.Background(), `SELECT COUNT(*) FROM posts`).Scan(&totalCount)
+// upsertOne sends the statements of a post, between SAVEPOINT post and its release, as one batch.
+// On error the transaction is left failed at the savepoint.
+func upsertOne(ctx context.Context, tx pgx.Tx, p storage.Post) (upsertOutcome, error) {
+	batch := &pgx.Batch{}
+	batch.Queue(`SAVEPOINT post;`)
+	if hasGUID(p) {
+		batch.Queue(relinkByGUID, p.Source.FeedID, p.GUID, p.Link)
+	}
+	batch.Queue(`
+		INSERT INTO posts (title, content, pub_time, link, feed_id, source_title, source_link, simhash, cluster_id, full_content, content_html, author, guid, date_estimated)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7, $8, (`+clusterOf+`), $9, $10, $11, $12, $13)
+		ON CONFLICT (link) DO UPDATE SET
+			title = EXCLUDED.title,
+			content = EXCLUDED.content,
+			content_html = EXCLUDED.content_html,
+			author = EXCLUDED.author,
+			guid = CASE WHEN posts.guid = '' AND NOT EXISTS (
+				SELECT 1 FROM posts o WHERE o.feed_id = posts.feed_id AND o.guid = EXCLUDED.guid
+			) THEN EXCLUDED.guid ELSE posts.guid END,
+			simhash = EXCLUDED.simhash,
+			pub_time = CASE WHEN posts.date_estimated AND NOT EXCLUDED.date_estimated THEN EXCLUDED.pub_time ELSE posts.pub_time END,
+			date_estimated = posts.date_estimated AND EXCLUDED.date_estimated,
+			full_content = COALESCE(NULLIF(EXCLUDED.full_content, ''), posts.full_content)
+		WHERE
+			posts.title IS DISTINCT FROM EXCLUDED.title
+			OR posts.content IS DISTINCT FROM EXCLUDED.content
+			OR posts.content_html IS DISTINCT FROM EXCLUDED.content_html
+			OR posts.author IS DISTINCT FROM EXCLUDED.author
+			OR (posts.guid = '' AND EXCLUDED.guid <> '' AND NOT EXISTS (
+				SELECT 1 FROM posts o WHERE o.feed_id = posts.feed_id AND o.guid = EXCLUDED.guid
+			))
+			OR (posts.date_estimated AND NOT EXCLUDED.date_estimated)
+			OR (EXCLUDED.full_content <> '' AND posts.full_content IS DISTINCT FROM EXCLUDED.full_content)
+		RETURNING
+			(xmax = 0) AS inserted;
+		`,
+		p.Title, p.Content, p.PubTime, p.Link, p.Source.FeedID, p.Source.Title, p.Source.Link, fingerprint(p), p.FullContent, p.ContentHTML, p.Author, p.GUID, p.DateEstimated,
+	)
+	if len(p.Media) > 0 {
+		batch.Queue(saveMedia, mediaArgs(p)...)
+	}
+	if len(p.Tags) > 0 {
+		batch.Queue(saveTags, p.Link, p.Tags)
+	}
+	batch.Queue(`RELEASE SAVEPOINT post;`)
+
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
+
+	_, err := br.Exec()
+	if err != nil {
+		return postUnchanged, fmt.Errorf("failed to set savepoint for post %s: %w", p.Link, err)
+	}
+
+	var relinked bool
+	if hasGUID(p) {
+		tag, err := br.Exec()
+		if err != nil {
+			return postUnchanged, fmt.Errorf("failed to relink post %s: %w", p.Link, err)
+		}
+		relinked = tag.RowsAffected() > 0
+	}
+
+	var outcome upsertOutcome
+	var inserted bool
+	err = br.QueryRow().Scan(&inserted)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows) && relinked:
+		outcome = postUpdated
+	case errors.Is(err, pgx.ErrNoRows):
+		outcome = postUnchanged
+	case err != nil:
+		return postUnchanged, fmt.Errorf("failed to upsert post %s: %w", p.Link, err)
+	case inserted:
+		outcome = postInserted
+	default:
+		outcome = postUpdated
+	}
+
+	if len(p.Media) > 0 {
+		_, err = br.Exec()
+		if err != nil {
+			return postUnchanged, fmt.Errorf("failed to save media of post %s: %w", p.Link, err)
+		}
+	}
+	if len(p.Tags) > 0 {
+		_, err = br.Exec()
+		if err != nil {
+			return postUnchanged, fmt.Errorf("failed to save tags of post %s: %w", p.Link, err)
+		}
+	}
+
+	_, err = br.Exec()
+	if err != nil {
+		return postUnchanged, fmt.Errorf("failed to release savepoint for post %s: %w", p.Link, err)
+	}
+
+	err = br.Close()
 	if err != nil {
-		return nil, storage.Pagination{}, fmt.Errorf("failed to count posts: %w", err)
+		return postUnchanged, fmt.Errorf("failed to close batch: %w", err)
+	}
+	return outcome, nil
+}
+
+// truncate shortens s to at most n characters, ending it with an ellipsis if cut.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
 	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
 
-	offset := (page - 1) * perPage
+// hasGUID reports whether the post can be matched by the GUID of its feed.
+func hasGUID(p storage.Post) bool {
+	return p.GUID != "" && p.Source.FeedID != 0
+}
+
+// FullContentLinks reports which of the links belong to posts that already have
+// their full content extracted.
+func (ps *PostgresStorage) FullContentLinks(links []string) (map[string]bool, error) {
 	rows, err := ps.db.Query(context.Background(), `
-	SELECT 
-		id, 
-		title, 
-		content,
-		pub_time,
+	SELECT
 		link
-	FROM 
+	FROM
 		posts
-	ORDER BY 
-		pub_time DESC
-	LIMIT $1 OFFSET $2;
-    `,
-		perPage, offset)
+	WHERE
+		link = ANY($1)
+		AND full_content <> '';
+	`,
+		links)
 	if err != nil {
-		return nil, storage.Pagination{}, fmt.Errorf("failed to fetch posts: %w", err)
+		return nil, fmt.Errorf("failed to execute query for FullContentLinks: %w", err)
 	}
 	defer rows.Close()
 
-	var posts []storage.Post
+	done := make(map[string]bool)
 	for rows.Next() {
-		var p storage.Post
-		err = rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Content,
-			&p.PubTime,
-			&p.Link,
-		)
+		var link string
+		err := rows.Scan(&link)
 		if err != nil {
-			return nil, storage.Pagination{}, fmt.Errorf("failed to scan post row: %w", err)
+			return nil, fmt.Errorf("failed to scan link: %w", err)
 		}
+		done[link] = true
+	}
 
-		posts = append(posts, p)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
 	}
 
-	totalPages := int(math.Ceil(float64(totalCount) / float64(perPage)))
+	return done, nil
+}
 
-	pagination := storage.Pagination{
+// paginate describes the requested page of a list with totalCount items.
+func paginate(page, perPage, totalCount int) storage.Pagination {
+	totalPages := int(math.Ceil(float64(totalCount) / float64(perPage)))
+	return storage.Pagination{
 		CurrentPage: page,
 		TotalPages:  totalPages,
 		PerPage:     perPage,
+		TotalItems:  totalCount,
+		HasNext:     page < totalPages,
+		HasPrev:     page > 1,
 	}
-
-	return posts, pagination, nil
 }
 
-// AddPost adds a new post to the database.
-func (ps *PostgresStorage) AddPost(p storage.Post) (storage.Post, error) {
-	var post storage.Post
-	err := ps.db.QueryRow(context.Background(), `
-	INSERT INTO posts (title, content, pub_time, link)
-	VALUES ($1, $2, $3, $4)
-	RETURNING 
-		id, title, content, pub_time, link;
-	`,
-		p.Title, p.Content, p.PubTime, p.Link,
-	).Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.PubTime,
-		&post.Link,
-	)
+// attachRelations loads the media and tags of the posts.
+func (ps *PostgresStorage) attachRelations(posts ...*storage.Post) error {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	media, err := ps.mediaByPost(ids)
 	if err != nil {
-		return post, fmt.Errorf("failed to create post: %w", err)
+		return err
+	}
+	tags, err := ps.tagsByPost(ids)
+	if err != nil {
+		return err
 	}
 
-	return post, nil
+	for _, p := range posts {
+		p.Media = media[p.ID]
+		p.Tags = tags[p.ID]
+	}
+	return nil
 }
 
-// SearchPosts returns posts whose titles contain the given substring.
-func (ps *PostgresStorage) SearchPosts(search string) ([]storage.Post, error) {
-	rows, err := ps.db.Query(context.Background(), `
-	SELECT 
-		id, 
-		title, 
-		content,
-		pub_time,
-		link
-	FROM 
-		posts
-	WHERE 
-		title
-	ILIKE '%' || $1 || '%';
-	`,
-		search)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute query for Posts: %w", err)
+// pointers returns pointers to the posts of the slice.
+func pointers(posts []storage.Post) []*storage.Post {
+	out := make([]*storage.Post, len(posts))
+	for i := range posts {
+		out[i] = &posts[i]
 	}
+	return out
+}
+
+// collectPosts scans all rows into posts and closes them.
+func collectPosts(rows pgx.Rows) ([]storage.Post, error) {
 	defer rows.Close()
 
 	var posts []storage.Post
 	for rows.Next() {
-		var p storage.Post
-		err = rows.Scan(
-			&p.ID,
-			&p.Title,
-			&p.Content,
-			&p.PubTime,
-			&p.Link,
-		)
+		p, err := scanPost(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan post row: %w", err)
+			return nil, err
 		}
 
 		posts = append(posts, p)
@@ -207,6 +492,38 @@ func (ps *PostgresStorage) SearchPosts(search string) ([]storage.Post, error) {
 	return posts, nil
 }
 
+// scanPost reads a posts row, mapping a NULL feed_id to zero.
+// Columns selected after postColumns are scanned into extra.
+func scanPost(row pgx.Row, extra ...any) (storage.Post, error) {
+	var p storage.Post
+	var feedID *int
+	dest := []any{
+		&p.ID,
+		&p.Title,
+		&p.Content,
+		&p.ContentHTML,
+		&p.FullContent,
+		&p.Author,
+		&p.GUID,
+		&p.PubTime,
+		&p.DateEstimated,
+		&p.Link,
+		&feedID,
+		&p.Source.Title,
+		&p.Source.Link,
+		&p.ClusterID,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return p, fmt.Errorf("failed to scan post row: %w", err)
+	}
+
+	if feedID != nil {
+		p.Source.FeedID = *feedID
+	}
+	return p, nil
+}
+
 // ClearPosts clears the table.
 func (ps *PostgresStorage) ClearPosts() error {
 	_, err := ps.db.Exec(context.Background(), `DELETE FROM posts;`)
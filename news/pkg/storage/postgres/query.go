@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"news/pkg/storage"
+	"strconv"
+	"strings"
+)
+
+// headlineOptions configures the content snippets returned for full-text queries.
+const headlineOptions = `StartSel=<b>, StopSel=</b>, MaxWords=35, MinWords=15, MaxFragments=2`
+
+// queryBuilder collects the WHERE conditions of a posts query and numbers their parameters.
+type queryBuilder struct {
+	conds []string
+	args  []any
+	// tsquery is the full-text query expression, empty unless the filter has a search.
+	tsquery string
+}
+
+// arg adds a parameter and returns its placeholder.
+func (b *queryBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// where adds a condition.
+func (b *queryBuilder) where(cond string) {
+	b.conds = append(b.conds, cond)
+}
+
+// filter adds the conditions of the post filter and must come before any other condition.
+// The search is matched as web search syntax under both the Russian and English
+// configurations, so that either language's morphology finds the post.
+// Unless duplicates are asked for, the filtered posts are collapsed to the oldest
+// matching post of each story cluster: a post is left out when an older post of its cluster
+// matches the filter too. That is a lookup on the cluster index for each row, so the list
+// keeps using the pub_time indexes for its order and limit.
+func (b *queryBuilder) filter(f storage.PostFilter) {
+	match := b.matcher(f)
+	for _, cond := range match("posts") {
+		b.where(cond)
+	}
+	if f.Duplicates {
+		return
+	}
+
+	older := match("o")
+	if len(older) == 0 {
+		// Every post of a cluster is newer than the first one, which has no cluster_id.
+		b.where("posts.cluster_id IS NULL")
+		return
+	}
+	b.where(`(posts.cluster_id IS NULL OR NOT EXISTS (
+		SELECT 1 FROM posts o
+		WHERE
+			(o.id = posts.cluster_id OR o.cluster_id = posts.cluster_id)
+			AND o.id < posts.id
+			AND ` + strings.Join(older, " AND ") + `
+	))`)
+}
+
+// matcher adds the parameters of the post filter and returns a function that writes
+// its conditions against the posts table under the given name.
+func (b *queryBuilder) matcher(f storage.PostFilter) func(table string) []string {
+	var conds []func(table string) string
+	if f.Source != 0 {
+		source := b.arg(f.Source)
+		conds = append(conds, func(table string) string { return table + ".feed_id = " + source })
+	}
+	if !f.From.IsZero() {
+		from := b.arg(f.From.UTC())
+		conds = append(conds, func(table string) string { return table + ".pub_time >= " + from })
+	}
+	if !f.To.IsZero() {
+		to := b.arg(f.To.UTC())
+		conds = append(conds, func(table string) string { return table + ".pub_time <= " + to })
+	}
+	if f.Tag != "" {
+		tag := b.arg(f.Tag)
+		conds = append(conds, func(table string) string {
+			return "EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = " + table + ".id AND t.name = " + tag + ")"
+		})
+	}
+	if f.Search != "" {
+		search := b.arg(f.Search)
+		b.tsquery = fmt.Sprintf("(websearch_to_tsquery('russian', %s) || websearch_to_tsquery('english', %s))", search, search)
+		conds = append(conds, func(table string) string { return table + ".search_vector @@ " + b.tsquery })
+	}
+
+	return func(table string) []string {
+		out := make([]string, len(conds))
+		for i, cond := range conds {
+			out[i] = cond(table)
+		}
+		return out
+	}
+}
+
+// conditions returns the collected conditions joined for a WHERE clause.
+func (b *queryBuilder) conditions() string {
+	if len(b.conds) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(b.conds, " AND ")
+}
+
+// orderBy returns the ORDER BY list for the sort. Relevance falls back to newest first
+// when there is no full-text query to rank by.
+func (b *queryBuilder) orderBy(sort storage.Sort) string {
+	switch {
+	case sort == storage.SortOldest:
+		return "pub_time ASC, id ASC"
+	case sort == storage.SortRelevance && b.tsquery != "",
+		sort == "" && b.tsquery != "":
+		return "rank DESC, pub_time DESC, id DESC"
+	default:
+		return "pub_time DESC, id DESC"
+	}
+}
+
+// QueryPosts returns a page of the news list selected by the filter and ordered by the sort,
+// together with the pagination details. Each post comes with the number of its duplicates, the
+// other posts of its story cluster, whether it is the first post of the story or a later one.
+// Full-text matches on the title weigh more than matches on the content; each one comes with
+// its rank and a highlighted snippet.
+func (ps *PostgresStorage) QueryPosts(q storage.PostQuery) ([]storage.SearchResult, storage.Pagination, error) {
+	var b queryBuilder
+	b.filter(q.PostFilter)
+
+	var totalCount int
+	err := ps.db.QueryRow(context.Background(), `
+	SELECT COUNT(*) FROM posts WHERE `+b.conditions()+`
+	`,
+		b.args...,
+	).Scan(&totalCount)
+	if err != nil {
+		return nil, storage.Pagination{}, fmt.Errorf("failed to count posts: %w", err)
+	}
+
+	rank, headline := "0::real", "''"
+	if b.tsquery != "" {
+		rank = "ts_rank(search_vector, " + b.tsquery + ")"
+		headline = "ts_headline('russian', content, " + b.tsquery + ", " + b.arg(headlineOptions) + ")"
+	}
+	order := b.orderBy(q.Sort)
+
+	// Headlines are costly, so they are built only for the rows of the requested page.
+	offset := (q.Page - 1) * q.PerPage
+	rows, err := ps.db.Query(context.Background(), `
+	SELECT`+postColumns+`,
+		rank,
+		`+headline+`,
+		duplicates
+	FROM (
+		SELECT
+			posts.*,
+			`+rank+` AS rank,
+			(SELECT COUNT(*) FROM posts c
+				WHERE (c.id = COALESCE(posts.cluster_id, posts.id) OR c.cluster_id = COALESCE(posts.cluster_id, posts.id))
+					AND c.id <> posts.id) AS duplicates
+		FROM
+			posts
+		WHERE
+			`+b.conditions()+`
+		ORDER BY
+			`+order+`
+		LIMIT `+b.arg(q.PerPage)+` OFFSET `+b.arg(offset)+`
+	) AS hits
+	ORDER BY
+		`+order+`;
+	`,
+		b.args...)
+	if err != nil {
+		return nil, storage.Pagination{}, fmt.Errorf("failed to execute query for QueryPosts: %w", err)
+	}
+	defer rows.Close()
+
+	var results []storage.SearchResult
+	for rows.Next() {
+		var r storage.SearchResult
+		var rank float32
+		r.Post, err = scanPost(rows, &rank, &r.Headline, &r.Duplicates)
+		if err != nil {
+			return nil, storage.Pagination{}, err
+		}
+		r.Rank = float64(rank)
+
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, storage.Pagination{}, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	posts := make([]*storage.Post, len(results))
+	for i := range results {
+		posts[i] = &results[i].Post
+	}
+	err = ps.attachRelations(posts...)
+	if err != nil {
+		return nil, storage.Pagination{}, err
+	}
+
+	return results, paginate(q.Page, q.PerPage, totalCount), nil
+}
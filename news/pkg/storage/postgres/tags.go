@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"news/pkg/storage"
+)
+
+// saveTags tags the post with link $1 with the names in $2, creating unknown tags.
+// Tags already on the post are kept.
+const saveTags = `
+	WITH t AS (
+		INSERT INTO tags (name)
+		SELECT DISTINCT unnest($2::text[])
+		ON CONFLICT (name) DO UPDATE SET
+			name = EXCLUDED.name
+		RETURNING id
+	)
+	INSERT INTO post_tags (post_id, tag_id)
+	SELECT
+		p.id, t.id
+	FROM
+		posts p, t
+	WHERE
+		p.link = $1
+	ON CONFLICT DO NOTHING;`
+
+// Tags returns the tags in use with their post counts, most used first.
+func (ps *PostgresStorage) Tags() ([]storage.Tag, error) {
+	rows, err := ps.db.Query(context.Background(), `
+	SELECT
+		t.name,
+		COUNT(*) AS posts
+	FROM
+		tags t
+		JOIN post_tags pt ON pt.tag_id = t.id
+	GROUP BY
+		t.name
+	ORDER BY
+		posts DESC, t.name;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for Tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []storage.Tag
+	for rows.Next() {
+		var t storage.Tag
+		err := rows.Scan(&t.Name, &t.Posts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag row: %w", err)
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return tags, nil
+}
+
+// tagsByPost returns the tag names of the posts by post ID, in alphabetical order.
+func (ps *PostgresStorage) tagsByPost(ids []int) (map[int][]string, error) {
+	tags := make(map[int][]string)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+
+	rows, err := ps.db.Query(context.Background(), `
+	SELECT
+		pt.post_id,
+		t.name
+	FROM
+		post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+	WHERE
+		pt.post_id = ANY($1)
+	ORDER BY
+		pt.post_id, t.name;
+	`,
+		ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query for post tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var name string
+		err := rows.Scan(&postID, &name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post tag row: %w", err)
+		}
+		tags[postID] = append(tags[postID], name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return tags, nil
+}
+
+// ClearTags clears the tags of all posts.
+func (ps *PostgresStorage) ClearTags() error {
+	_, err := ps.db.Exec(context.Background(), `DELETE FROM tags;`)
+	if err != nil {
+		return fmt.Errorf("failed to clean up tags table: %w", err)
+	}
+	return nil
+}
@@ -1,14 +1,46 @@
 package storage
 
-import "time"
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
 
 // Post represents a single news article.
+// ClusterID groups near-duplicate posts of the same story; it is the ID of the story's first post.
 type Post struct {
-	ID      int
-	Title   string
-	Content string
-	PubTime time.Time
-	Link    string
+	ID            int
+	Title         string
+	Content       string
+	ContentHTML   string // Content as sanitized HTML
+	Author        string
+	GUID          string // the feed's stable item ID, unique per feed when set
+	FullContent   string // article body extracted from Link, empty unless the feed enables full text
+	PubTime       time.Time
+	DateEstimated bool // the feed gave no usable date; PubTime is when the post was first fetched
+	Link          string
+	Source        Source
+	ClusterID     int
+	Media         []Media
+	Tags          []string // normalized, see NormalizeTag
+}
+
+// Media is an image, audio or video attached to a post: a feed enclosure, a Media RSS
+// item or an image embedded in the description. Zero sizes are unknown.
+type Media struct {
+	URL    string
+	Type   string // MIME type
+	Length int64  // bytes
+	Width  int
+	Height int
+}
+
+// Source identifies the feed a post came from: its ID, channel title and site link.
+type Source struct {
+	FeedID int
+	Title  string
+	Link   string
 }
 
 // Pagination represents pagination details for a list of news.
@@ -16,13 +48,143 @@ type Pagination struct {
 	CurrentPage int
 	TotalPages  int
 	PerPage     int
+	TotalItems  int
+	HasNext     bool
+	HasPrev     bool
+}
+
+// Cursor marks a post in the news list ordered by pub_time and ID, newest first.
+// A forward cursor asks for the older posts after it, a Before cursor for the newer posts before it.
+type Cursor struct {
+	PubTime time.Time
+	ID      int
+	Before  bool
+}
+
+// CursorPage holds the cursors of the neighbouring pages; nil means there is no such page.
+type CursorPage struct {
+	Next *Cursor
+	Prev *Cursor
+}
+
+// PostFilter narrows the news list; zero fields do not filter.
+// From and To bound pub_time inclusively, Search is a full-text query, Tag a normalized tag.
+// The list shows one post per story cluster unless Duplicates is set.
+type PostFilter struct {
+	Source     int
+	From       time.Time
+	To         time.Time
+	Search     string
+	Tag        string
+	Duplicates bool
+}
+
+// Tag is a topic with the number of posts carrying it.
+type Tag struct {
+	Name  string
+	Posts int
+}
+
+// maxTagLength bounds tag names, in characters.
+const maxTagLength = 64
+
+// NormalizeTag turns a feed category into a tag: lower case, single spaces and no leading '#'.
+// Categories that are empty or longer than 64 characters yield an empty tag.
+func NormalizeTag(category string) string {
+	tag := strings.Join(strings.Fields(strings.ToLower(category)), " ")
+	tag = strings.TrimSpace(strings.TrimLeft(tag, "#"))
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return ""
+	}
+	return tag
+}
+
+// Sort orders the news list.
+type Sort string
+
+const (
+	SortNewest    Sort = "-pub_time"
+	SortOldest    Sort = "pub_time"
+	SortRelevance Sort = "relevance"
+)
+
+// PostQuery selects a page of the news list.
+// An empty Sort means relevance for full-text queries and newest first otherwise.
+type PostQuery struct {
+	PostFilter
+	Sort    Sort
+	Page    int
+	PerPage int
+}
+
+// SearchResult is a post of the news list. For full-text queries it also carries
+// the relevance rank and a snippet of the content with the matched terms wrapped in <b> tags.
+// Duplicates counts the other posts of the same story cluster.
+type SearchResult struct {
+	Post       Post
+	Rank       float64
+	Headline   string
+	Duplicates int
+}
+
+// UpsertResult reports how a batch of posts or feeds was applied to the storage.
+// Skipped counts posts that could not be stored while the rest of the batch was.
+type UpsertResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Skipped   int
+}
+
+// ErrSkipped is wrapped by the error of an upsert that stored the batch but skipped some
+// of its posts; the error tells why each of them was skipped.
+var ErrSkipped = errors.New("some posts were skipped")
+
+// FeedState holds the HTTP cache validators remembered for a feed between polls.
+type FeedState struct {
+	URL          string
+	ETag         string
+	LastModified string
+}
+
+// Feed represents a polled source, its settings and its health.
+// Interval is in minutes; zero means the default request period.
+// MaxBodySize bounds the feed document in bytes; zero means the configured default.
+// Category is the slash-delimited folder of the feed in OPML lists, such as "Tech/Go".
+// Zero times mean the feed has never been attempted or never succeeded.
+type Feed struct {
+	ID            int
+	URL           string
+	Title         string
+	Category      string
+	Interval      int
+	Enabled       bool
+	FullText      bool // extract the article body of every post from its page
+	MaxBodySize   int64
+	LastAttempt   time.Time
+	LastSuccess   time.Time
+	LastError     string
+	Failures      int
+	ItemsIngested int
 }
 
 // Interface defines the behavior of a storage system for posts.
 type Storage interface {
 	Post(newsID int) (Post, error)
-	Posts(limit int) ([]Post, error)
 	AddPost(p Post) (Post, error)
-	SearchPosts(search string) ([]Post, error)
-	GetPostsPaginated(page, perPage int) ([]Post, Pagination, error)
+	UpsertPosts(posts []Post) (UpsertResult, error)
+	QueryPosts(q PostQuery) ([]SearchResult, Pagination, error)
+	PostsByCursor(c *Cursor, limit int, f PostFilter) ([]Post, CursorPage, error)
+	ClusterPosts(clusterID int) ([]Post, error)
+	FullContentLinks(links []string) (map[string]bool, error)
+	Tags() ([]Tag, error)
+	FeedState(url string) (FeedState, error)
+	SaveFeedState(s FeedState) error
+	RecordFetch(url string, items int, fetchErr error) error
+	Feeds() ([]Feed, error)
+	Feed(id int) (Feed, error)
+	AddFeed(f Feed) (Feed, error)
+	ImportFeeds(feeds []Feed) (UpsertResult, error)
+	UpdateFeed(f Feed) (Feed, error)
+	DeleteFeed(id int) error
 }
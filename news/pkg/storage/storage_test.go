@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		category string
+		want     string
+	}{
+		{category: "Go", want: "go"},
+		{category: "  Open \t Source ", want: "open source"},
+		{category: "#Новости", want: "новости"},
+		{category: "##", want: ""},
+		{category: "", want: ""},
+		{category: strings.Repeat("я", 64), want: strings.Repeat("я", 64)},
+		{category: strings.Repeat("я", 65), want: ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTag(tt.category); got != tt.want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", tt.category, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package syndicate publishes aggregated posts as RSS 2.0 and Atom 1.0 feeds, so other
+// readers can subscribe to the news list.
+package syndicate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"news/pkg/storage"
+	"time"
+)
+
+// Channel describes the published feed. Link is the page of the list, Self the address
+// of the feed document itself.
+type Channel struct {
+	Title       string
+	Description string
+	Link        string
+	Self        string
+}
+
+// Updated returns the time of the newest post, the zero time if there are none.
+func Updated(posts []storage.Post) time.Time {
+	var updated time.Time
+	for _, p := range posts {
+		if p.PubTime.After(updated) {
+			updated = p.PubTime
+		}
+	}
+	return updated
+}
+
+// content returns the post content as HTML: the sanitized HTML when the feed carried it,
+// the escaped plain text otherwise.
+func content(p storage.Post) string {
+	if p.ContentHTML != "" {
+		return p.ContentHTML
+	}
+	return html.EscapeString(p.Content)
+}
+
+// rssDocument is an RSS 2.0 document with the dc and atom namespaces used by its items.
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          *atomLink `xml:"atom:link,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Creator     string        `xml:"dc:creator,omitempty"`
+	PubDate     string        `xml:"pubDate"`
+	GUID        rssGUID       `xml:"guid"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// RSS writes the posts as an RSS 2.0 feed, in the order given. Items are identified by their
+// link, the first medium with a known type becomes the enclosure and tags become categories.
+func RSS(w io.Writer, c Channel, posts []storage.Post) error {
+	ch := rssChannel{
+		Title:       c.Title,
+		Link:        c.Link,
+		Description: c.Description,
+		Items:       make([]rssItem, len(posts)),
+	}
+	if c.Self != "" {
+		ch.Self = &atomLink{Href: c.Self, Rel: "self", Type: "application/rss+xml"}
+	}
+	if updated := Updated(posts); !updated.IsZero() {
+		ch.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for i, p := range posts {
+		item := rssItem{
+			Title:       p.Title,
+			Link:        p.Link,
+			Description: content(p),
+			Creator:     p.Author,
+			PubDate:     p.PubTime.UTC().Format(time.RFC1123Z),
+			GUID:        rssGUID{IsPermaLink: true, Value: p.Link},
+			Categories:  p.Tags,
+		}
+		for _, m := range p.Media {
+			if m.Type != "" {
+				item.Enclosure = &rssEnclosure{URL: m.URL, Length: m.Length, Type: m.Type}
+				break
+			}
+		}
+		ch.Items[i] = item
+	}
+
+	return write(w, rssDocument{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: ch,
+	})
+}
+
+// atomFeed is an Atom 1.0 feed document.
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomText       `xml:"content"`
+	Source     *atomSource    `xml:"source,omitempty"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// atomSource names the feed an entry was aggregated from.
+type atomSource struct {
+	Title string    `xml:"title,omitempty"`
+	Link  *atomLink `xml:"link,omitempty"`
+}
+
+// Atom writes the posts as an Atom 1.0 feed, in the order given. Entries are identified by
+// their link, media become enclosure links and the originating feed is named as the source.
+// A feed without posts is dated at the Unix epoch so that it stays the same between requests.
+func Atom(w io.Writer, c Channel, posts []storage.Post) error {
+	updated := Updated(posts)
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+
+	feed := atomFeed{
+		Title:    c.Title,
+		Subtitle: c.Description,
+		ID:       c.Self,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links:    []atomLink{{Href: c.Link, Rel: "alternate", Type: "text/html"}},
+		Entries:  make([]atomEntry, len(posts)),
+	}
+	if c.Self != "" {
+		feed.Links = append(feed.Links, atomLink{Href: c.Self, Rel: "self", Type: "application/atom+xml"})
+	} else {
+		feed.ID = c.Link
+	}
+
+	for i, p := range posts {
+		e := atomEntry{
+			Title:     p.Title,
+			ID:        p.Link,
+			Updated:   p.PubTime.UTC().Format(time.RFC3339),
+			Published: p.PubTime.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: p.Link, Rel: "alternate"}},
+			Content:   atomText{Type: "html", Value: content(p)},
+		}
+		if p.Author != "" {
+			e.Author = &atomPerson{Name: p.Author}
+		}
+		for _, m := range p.Media {
+			e.Links = append(e.Links, atomLink{Href: m.URL, Rel: "enclosure", Type: m.Type, Length: m.Length})
+		}
+		for _, t := range p.Tags {
+			e.Categories = append(e.Categories, atomCategory{Term: t})
+		}
+		if p.Source.Title != "" || p.Source.Link != "" {
+			e.Source = &atomSource{Title: p.Source.Title}
+			if p.Source.Link != "" {
+				e.Source.Link = &atomLink{Href: p.Source.Link, Rel: "alternate"}
+			}
+		}
+		feed.Entries[i] = e
+	}
+
+	return write(w, feed)
+}
+
+// write writes the XML declaration and the indented document.
+func write(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
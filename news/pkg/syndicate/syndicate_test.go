@@ -0,0 +1,110 @@
+package syndicate
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"news/pkg/rss"
+	"news/pkg/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+var posts = []storage.Post{
+	{
+		Title:       "Go 1.22 & loops",
+		Link:        "https://go.dev/blog/loopvar",
+		Content:     "Loop variables are per iteration.",
+		ContentHTML: "<p>Loop variables are <b>per iteration</b>.</p>",
+		Author:      "Russ Cox",
+		PubTime:     time.Date(2024, 2, 6, 10, 0, 0, 0, time.UTC),
+		Tags:        []string{"go", "release"},
+		Media:       []storage.Media{{URL: "https://go.dev/gopher.png", Type: "image/png", Length: 1024}},
+		Source:      storage.Source{FeedID: 1, Title: "The Go Blog", Link: "https://go.dev/blog/"},
+	},
+	{
+		Title:   "Plain <text>",
+		Link:    "https://example.com/2",
+		Content: "a < b",
+		PubTime: time.Date(2024, 2, 5, 8, 30, 0, 0, time.UTC),
+	},
+}
+
+var channel = Channel{
+	Title:       "News",
+	Description: "Aggregated news",
+	Link:        "https://news.example.com/",
+	Self:        "https://news.example.com/news.atom",
+}
+
+// TestWriters checks that the feeds we publish read back with our own parser.
+func TestWriters(t *testing.T) {
+	writers := map[string]func(io.Writer, Channel, []storage.Post) error{"RSS": RSS, "Atom": Atom}
+	for name, write := range writers {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(&buf, channel, posts); err != nil {
+				t.Fatalf("%s() error = %v", name, err)
+			}
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(buf.Bytes())
+			}))
+			defer ts.Close()
+
+			res, err := rss.Fetch(context.Background(), ts.Client(), ts.URL, rss.Validators{}, 0)
+			if err != nil {
+				t.Fatalf("rss.Fetch() of %s output error = %v\n%s", name, err, buf.String())
+			}
+			if res.Title != channel.Title {
+				t.Errorf("Title = %q, want %q", res.Title, channel.Title)
+			}
+			if len(res.Posts) != len(posts) {
+				t.Fatalf("got %d posts, want %d", len(res.Posts), len(posts))
+			}
+
+			for i, got := range res.Posts {
+				want := posts[i]
+				if got.Title != want.Title || got.Link != want.Link || got.GUID != want.Link || got.Author != want.Author {
+					t.Errorf("post %d = %q %q %q %q, want %q %q %q %q", i, got.Title, got.Link, got.GUID, got.Author, want.Title, want.Link, want.Link, want.Author)
+				}
+				if !got.PubTime.Equal(want.PubTime) || got.DateEstimated {
+					t.Errorf("post %d PubTime = %v, want %v", i, got.PubTime, want.PubTime)
+				}
+				if strings.Join(got.Tags, ",") != strings.Join(want.Tags, ",") {
+					t.Errorf("post %d Tags = %q, want %q", i, got.Tags, want.Tags)
+				}
+				if len(got.Media) != len(want.Media) {
+					t.Errorf("post %d Media = %+v, want %+v", i, got.Media, want.Media)
+				}
+			}
+
+			if !strings.Contains(res.Posts[0].ContentHTML, "<b>per iteration</b>") {
+				t.Errorf("ContentHTML = %q, want the sanitized HTML of the post", res.Posts[0].ContentHTML)
+			}
+			if !strings.Contains(res.Posts[1].ContentHTML, "a &lt; b") {
+				t.Errorf("ContentHTML = %q, want the escaped plain text of the post", res.Posts[1].ContentHTML)
+			}
+		})
+	}
+}
+
+func TestAtom_Empty(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := Atom(&first, channel, nil); err != nil {
+		t.Fatalf("Atom() error = %v", err)
+	}
+	if err := Atom(&second, channel, nil); err != nil {
+		t.Fatalf("Atom() error = %v", err)
+	}
+
+	if first.String() != second.String() {
+		t.Errorf("empty feeds differ between calls:\n%s\n%s", first.String(), second.String())
+	}
+	if !strings.Contains(first.String(), "<updated>1970-01-01T00:00:00Z</updated>") {
+		t.Errorf("empty feed lacks a fixed updated date:\n%s", first.String())
+	}
+}